@@ -0,0 +1,376 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/hasura/go-graphql-client/pkg/parser"
+)
+
+// QueryBuilder builds the selection set of an operation at runtime.
+// It's an alternative to struct tags for queries whose shape is only known at runtime,
+// and it is rendered by ConstructQuery, ConstructMutation and ConstructSubscription the same as a struct.
+//
+//	q := graphql.NewQueryBuilder()
+//	user := q.Field("user").Alias("me").Arg("id", graphql.Var("id"))
+//	user.Fields("id", "name")
+//	user.On("Admin").Fields("role")
+//
+// The query above is rendered as {me:user(id:$id){id,name,... on Admin{role}}}
+type QueryBuilder struct {
+	selectionSet
+}
+
+// NewQueryBuilder creates an empty query builder
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+// GenericSelection implements jsonutil.GenericSelection.
+// The data of a builder used as a map value is decoded into a generic tree
+func (*QueryBuilder) GenericSelection() {}
+
+// QueryDynamic executes the query built at runtime and decodes the response data into a generic tree of
+// map[string]interface{}, []interface{}, string, json.Number, bool and nil values.
+// Partial data is returned with GraphQL errors
+func (c *Client) QueryDynamic(ctx context.Context, q *QueryBuilder, variables interface{}, options ...Option) (map[string]interface{}, error) {
+	return c.doDynamic(ctx, queryOperation, q, variables, options)
+}
+
+// MutateDynamic executes the mutation built at runtime and decodes the response data into a generic tree.
+// See QueryDynamic for details
+func (c *Client) MutateDynamic(ctx context.Context, m *QueryBuilder, variables interface{}, options ...Option) (map[string]interface{}, error) {
+	return c.doDynamic(ctx, mutationOperation, m, variables, options)
+}
+
+func (c *Client) doDynamic(ctx context.Context, op operationType, b *QueryBuilder, variables interface{}, options []Option) (map[string]interface{}, error) {
+	data, resp, respBuf, errs := c.buildAndRequest(ctx, op, b, variables, options...)
+	var result map[string]interface{}
+	if len(data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&result); err != nil {
+			we := newDecodeError(err)
+			if c.debug {
+				we = we.withResponse(resp, respBuf)
+			}
+			errs = append(errs, we)
+		}
+	}
+	if len(errs) > 0 {
+		return result, errs
+	}
+	return result, nil
+}
+
+// Variable is a reference to an operation variable used as an argument value of the query builder
+type Variable string
+
+// Var returns a reference to the variable name, that is rendered as $name
+func Var(name string) Variable {
+	return Variable(name)
+}
+
+// EnumValue is an enum argument value of the query builder. It's rendered without quotes
+type EnumValue string
+
+// FieldBuilder is a field of the query builder
+type FieldBuilder struct {
+	selectionSet
+	name       string
+	alias      string
+	arguments  []builderArgument
+	directives []builderDirective
+}
+
+// Alias sets the alias of the field
+func (f *FieldBuilder) Alias(alias string) *FieldBuilder {
+	f.alias = alias
+	return f
+}
+
+// Arg adds an argument to the field.
+// The value can be a variable reference created by Var, an EnumValue, or a literal of
+// nil, string, boolean, number, slice, or map with string keys
+func (f *FieldBuilder) Arg(name string, value interface{}) *FieldBuilder {
+	f.arguments = append(f.arguments, builderArgument{name: name, value: value})
+	return f
+}
+
+// Directive adds a directive to the field, e.g. Directive("include", map[string]interface{}{"if": Var("withUser")})
+func (f *FieldBuilder) Directive(name string, arguments map[string]interface{}) *FieldBuilder {
+	f.directives = append(f.directives, builderDirective{name: name, arguments: arguments})
+	return f
+}
+
+// FragmentBuilder is an inline fragment of the query builder
+type FragmentBuilder struct {
+	selectionSet
+	typeCondition string
+	directives    []builderDirective
+}
+
+// Directive adds a directive to the inline fragment
+func (f *FragmentBuilder) Directive(name string, arguments map[string]interface{}) *FragmentBuilder {
+	f.directives = append(f.directives, builderDirective{name: name, arguments: arguments})
+	return f
+}
+
+type builderArgument struct {
+	name  string
+	value interface{}
+}
+
+type builderDirective struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// builderSelection is the common interface of FieldBuilder and FragmentBuilder
+type builderSelection interface {
+	write(w io.Writer) error
+}
+
+// selectionSet is the list of selections shared by QueryBuilder, FieldBuilder and FragmentBuilder
+type selectionSet struct {
+	selections []builderSelection
+}
+
+// Field adds a field to the selection set and returns it
+func (s *selectionSet) Field(name string) *FieldBuilder {
+	f := &FieldBuilder{name: name}
+	s.selections = append(s.selections, f)
+	return f
+}
+
+// Fields adds fields without arguments nor sub-selections to the selection set
+func (s *selectionSet) Fields(names ...string) {
+	for _, name := range names {
+		s.Field(name)
+	}
+}
+
+// On adds an inline fragment to the selection set and returns it.
+// The type condition can be empty to apply directives to a group of fields
+func (s *selectionSet) On(typeCondition string) *FragmentBuilder {
+	f := &FragmentBuilder{typeCondition: typeCondition}
+	s.selections = append(s.selections, f)
+	return f
+}
+
+// write writes the minified selection set to w
+func (s *selectionSet) write(w io.Writer) error {
+	if len(s.selections) == 0 {
+		return errors.New("the selection set is empty")
+	}
+	io.WriteString(w, "{")
+	for i, selection := range s.selections {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		if err := selection.write(w); err != nil {
+			return err
+		}
+	}
+	io.WriteString(w, "}")
+	return nil
+}
+
+func (f *FieldBuilder) write(w io.Writer) error {
+	if !isGraphQLName(f.name) {
+		return fmt.Errorf("invalid field name %q", f.name)
+	}
+	if f.alias != "" {
+		if !isGraphQLName(f.alias) {
+			return fmt.Errorf("invalid alias %q of field %s", f.alias, f.name)
+		}
+		io.WriteString(w, f.alias)
+		io.WriteString(w, ":")
+	}
+	io.WriteString(w, f.name)
+	if len(f.arguments) > 0 {
+		io.WriteString(w, "(")
+		for i, arg := range f.arguments {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if err := writeBuilderArgument(w, arg.name, arg.value); err != nil {
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+		io.WriteString(w, ")")
+	}
+	if err := writeBuilderDirectives(w, f.directives); err != nil {
+		return fmt.Errorf("field %s: %w", f.name, err)
+	}
+	if len(f.selections) == 0 {
+		return nil
+	}
+	if err := f.selectionSet.write(w); err != nil {
+		return fmt.Errorf("field %s: %w", f.name, err)
+	}
+	return nil
+}
+
+func (f *FragmentBuilder) write(w io.Writer) error {
+	io.WriteString(w, "...")
+	if f.typeCondition != "" {
+		if !isGraphQLName(f.typeCondition) {
+			return fmt.Errorf("invalid type condition %q", f.typeCondition)
+		}
+		io.WriteString(w, " on ")
+		io.WriteString(w, f.typeCondition)
+	}
+	if err := writeBuilderDirectives(w, f.directives); err != nil {
+		return err
+	}
+	if err := f.selectionSet.write(w); err != nil {
+		return fmt.Errorf("inline fragment on %s: %w", f.typeCondition, err)
+	}
+	return nil
+}
+
+func writeBuilderDirectives(w io.Writer, directives []builderDirective) error {
+	for _, d := range directives {
+		if !isGraphQLName(d.name) {
+			return fmt.Errorf("invalid directive name %q", d.name)
+		}
+		io.WriteString(w, "@")
+		io.WriteString(w, d.name)
+		if len(d.arguments) == 0 {
+			continue
+		}
+		// sort arguments to produce deterministic output
+		names := make([]string, 0, len(d.arguments))
+		for name := range d.arguments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		io.WriteString(w, "(")
+		for i, name := range names {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if err := writeBuilderArgument(w, name, d.arguments[name]); err != nil {
+				return fmt.Errorf("directive @%s: %w", d.name, err)
+			}
+		}
+		io.WriteString(w, ")")
+	}
+	return nil
+}
+
+func writeBuilderArgument(w io.Writer, name string, value interface{}) error {
+	if !isGraphQLName(name) {
+		return fmt.Errorf("invalid argument name %q", name)
+	}
+	io.WriteString(w, name)
+	io.WriteString(w, ":")
+	if err := writeArgumentValue(w, reflect.ValueOf(value)); err != nil {
+		return fmt.Errorf("argument %s: %w", name, err)
+	}
+	return nil
+}
+
+// writeArgumentValue writes the GraphQL literal of the argument value v to w
+func writeArgumentValue(w io.Writer, v reflect.Value) error {
+	if !v.IsValid() {
+		io.WriteString(w, "null")
+		return nil
+	}
+	switch value := v.Interface().(type) {
+	case Variable:
+		if !isGraphQLName(string(value)) {
+			return fmt.Errorf("invalid variable name %q", value)
+		}
+		io.WriteString(w, "$")
+		io.WriteString(w, string(value))
+		return nil
+	case EnumValue:
+		if !isGraphQLName(string(value)) {
+			return fmt.Errorf("invalid enum value %q", value)
+		}
+		io.WriteString(w, string(value))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			io.WriteString(w, "null")
+			return nil
+		}
+		return writeArgumentValue(w, v.Elem())
+	case reflect.String:
+		io.WriteString(w, parser.QuoteString(v.String()))
+	case reflect.Bool:
+		io.WriteString(w, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		io.WriteString(w, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		io.WriteString(w, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		io.WriteString(w, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			io.WriteString(w, "null")
+			return nil
+		}
+		io.WriteString(w, "[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if err := writeArgumentValue(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, "]")
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map keys must be strings, got %v", v.Type().Key())
+		}
+		if v.IsNil() {
+			io.WriteString(w, "null")
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		io.WriteString(w, "{")
+		for i, key := range keys {
+			if !isGraphQLName(key.String()) {
+				return fmt.Errorf("invalid object field name %q", key.String())
+			}
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			io.WriteString(w, key.String())
+			io.WriteString(w, ":")
+			if err := writeArgumentValue(w, v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		io.WriteString(w, "}")
+	default:
+		return fmt.Errorf("unsupported value type %v", v.Type())
+	}
+	return nil
+}
+
+var graphqlNameRegexp = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+func isGraphQLName(s string) bool {
+	return graphqlNameRegexp.MatchString(s)
+}
+
+var queryBuilderType = reflect.TypeOf(&QueryBuilder{})
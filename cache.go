@@ -0,0 +1,322 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
+)
+
+// queryCacheLimit bounds the number of cached documents, e.g. if operation names are generated per request.
+// The cache is cleared when the limit is reached
+const queryCacheLimit = 1024
+
+// queryCache holds the documents constructed from struct types,
+// so the hot path of Query, Mutate and Subscribe doesn't reflect the struct every time
+var queryCache = struct {
+	sync.RWMutex
+	documents map[queryCacheKey]cachedQuery
+}{
+	documents: map[queryCacheKey]cachedQuery{},
+}
+
+// queryCacheKey identifies everything the constructed document depends on
+// if the query type doesn't depend on values, see isStaticQueryType
+type queryCacheKey struct {
+	op        operationType
+	t         reflect.Type
+	variables string
+	options   string
+	naming    ident.NamingStrategy
+	// registry is the version of the registered unions, which are expanded in the document
+	registry uint64
+}
+
+type cachedQuery struct {
+	query         string
+	operationName string
+}
+
+// typeIDs interns reflect.Type values to numbers, to build signatures as comparable strings
+var (
+	typeIDs    sync.Map
+	nextTypeID uint64
+)
+
+// staticQueryTypes caches isStaticQueryType by staticQueryTypeKey
+var staticQueryTypes sync.Map
+
+type staticQueryTypeKey struct {
+	t        reflect.Type
+	registry uint64
+}
+
+// newQueryCacheKey returns the cache key of the operation,
+// or false if the document may depend on the value of v, e.g. maps, ordered maps and query builders.
+// Naming strategies that can't be map keys aren't cached either
+func newQueryCacheKey(op operationType, v interface{}, variables map[string]interface{}, options []Option, naming ident.NamingStrategy) (queryCacheKey, bool) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return queryCacheKey{}, false
+	}
+	if naming == nil {
+		naming = ident.LowerCamelCase
+	}
+	if !reflect.TypeOf(naming).Comparable() {
+		return queryCacheKey{}, false
+	}
+	registry := jsonutil.RegistryVersion()
+	if !isStaticQueryType(t, registry) {
+		return queryCacheKey{}, false
+	}
+	var opts strings.Builder
+	for _, option := range options {
+		opts.WriteString(string(option.Type()))
+		opts.WriteByte(0)
+		opts.WriteString(option.String())
+		opts.WriteByte(0)
+	}
+	return queryCacheKey{
+		op:        op,
+		t:         t,
+		variables: variablesSignature(variables),
+		options:   opts.String(),
+		naming:    naming,
+		registry:  registry,
+	}, true
+}
+
+// variablesSignature returns a string that identifies the names and types of variables.
+// GraphQLType values are identified by their GraphQL type too, which may depend on the value
+func variablesSignature(variables map[string]interface{}) string {
+	keys := make([]string, 0, len(variables))
+	for k := range variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(strconv.FormatUint(typeID(reflect.TypeOf(variables[k])), 36))
+		if v, ok := variables[k].(typedVariable); ok {
+			sb.WriteByte(0)
+			sb.WriteString(v.definition)
+		} else if o, ok := variables[k].(Optional); ok {
+			sb.WriteByte(0)
+			writeOptionalType(&sb, o)
+		} else if graphqlType, ok := graphQLTypeOf(reflect.TypeOf(variables[k]), variables[k]); ok {
+			sb.WriteByte(0)
+			sb.WriteString(graphqlType.GetGraphQLType())
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// typeID returns the unique number of type t, 0 for nil
+func typeID(t reflect.Type) uint64 {
+	if t == nil {
+		return 0
+	}
+	if id, ok := typeIDs.Load(t); ok {
+		return id.(uint64)
+	}
+	id, _ := typeIDs.LoadOrStore(t, atomic.AddUint64(&nextTypeID, 1))
+	return id.(uint64)
+}
+
+// isStaticQueryType reports whether the document written by writeQuery for t depends on the type only
+func isStaticQueryType(t reflect.Type, registry uint64) bool {
+	key := staticQueryTypeKey{t: t, registry: registry}
+	if static, ok := staticQueryTypes.Load(key); ok {
+		return static.(bool)
+	}
+	static := isStaticType(t, map[reflect.Type]bool{})
+	staticQueryTypes.Store(key, static)
+	return static
+}
+
+// isStaticType walks t the same way as writeQuery
+func isStaticType(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return true
+	}
+	visited[t] = true
+
+	if _, ok := jsonutil.RegisteredScalar(t); ok {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if t == queryBuilderType {
+			return false
+		}
+		return isStaticType(t.Elem(), visited)
+	case reflect.Struct:
+		if reflect.PtrTo(t).Implements(jsonUnmarshaler) || t.AssignableTo(idType) {
+			return true
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Tag.Get("graphql") == "-" || isTrue(f.Tag.Get("scalar")) {
+				continue
+			}
+			if !isStaticType(f.Type, visited) {
+				return false
+			}
+		}
+	case reflect.Slice:
+		// [][2]interface{} is an ordered map, whose keys are the selection set
+		if t.Elem().Kind() == reflect.Array {
+			return false
+		}
+		return isStaticType(t.Elem(), visited)
+	case reflect.Map:
+		return false
+	case reflect.Interface:
+		members, _ := jsonutil.UnionMembers(t)
+		for _, member := range members {
+			if !isStaticType(member.Type, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func loadCachedQuery(key queryCacheKey) (cachedQuery, bool) {
+	queryCache.RLock()
+	defer queryCache.RUnlock()
+	cached, ok := queryCache.documents[key]
+	return cached, ok
+}
+
+func storeCachedQuery(key queryCacheKey, cached cachedQuery) {
+	queryCache.Lock()
+	defer queryCache.Unlock()
+	if len(queryCache.documents) >= queryCacheLimit {
+		queryCache.documents = map[queryCacheKey]cachedQuery{}
+	}
+	queryCache.documents[key] = cached
+}
+
+// PreparedQuery is a GraphQL operation constructed once from the type of a query struct
+// and the types of the variables. It's safe for concurrent use, so it can be prepared
+// at init time and executed many times by Client.QueryPrepared or Client.MutatePrepared.
+//
+// The document of maps, ordered maps and query builders is constructed from the value passed to PrepareQuery
+type PreparedQuery struct {
+	op        operationType
+	t         reflect.Type
+	variables string
+	query     string
+	naming    ident.NamingStrategy
+}
+
+// PrepareQuery constructs the query document of q with variables of the same types as variables
+func PrepareQuery(q interface{}, variables interface{}, options ...Option) (*PreparedQuery, error) {
+	return prepareOperation(queryOperation, q, variables, options, nil)
+}
+
+// PrepareMutation constructs the mutation document of m with variables of the same types as variables
+func PrepareMutation(m interface{}, variables interface{}, options ...Option) (*PreparedQuery, error) {
+	return prepareOperation(mutationOperation, m, variables, options, nil)
+}
+
+// PrepareQuery constructs the query document of q with the naming strategy of the client
+func (c *Client) PrepareQuery(q interface{}, variables interface{}, options ...Option) (*PreparedQuery, error) {
+	return prepareOperation(queryOperation, q, variables, options, c.naming)
+}
+
+// PrepareMutation constructs the mutation document of m with the naming strategy of the client
+func (c *Client) PrepareMutation(m interface{}, variables interface{}, options ...Option) (*PreparedQuery, error) {
+	return prepareOperation(mutationOperation, m, variables, options, c.naming)
+}
+
+func prepareOperation(op operationType, v interface{}, variables interface{}, options []Option, naming ident.NamingStrategy) (*PreparedQuery, error) {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return nil, err
+	}
+	query, _, err := constructOperation(op, v, vars, options, naming)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedQuery{
+		op:        op,
+		t:         reflect.TypeOf(v),
+		variables: variablesSignature(vars),
+		query:     query,
+		naming:    naming,
+	}, nil
+}
+
+// String returns the GraphQL document of the prepared operation
+func (p *PreparedQuery) String() string {
+	return p.query
+}
+
+// check returns an error if v or variables don't have the types the operation was prepared with,
+// or the response would be decoded with another naming strategy
+func (p *PreparedQuery) check(op operationType, v interface{}, variables map[string]interface{}, naming ident.NamingStrategy) error {
+	if p == nil {
+		return errors.New("the prepared query is nil")
+	}
+	if p.op != op {
+		return fmt.Errorf("the prepared operation is a %s, not a %s", p.op, op)
+	}
+	if t := reflect.TypeOf(v); t != p.t {
+		return fmt.Errorf("the prepared %s expects %v, got %v", p.op, p.t, t)
+	}
+	if variablesSignature(variables) != p.variables {
+		return fmt.Errorf("the variables don't match the names and types of the prepared %s", p.op)
+	}
+	if !sameNamingStrategy(p.naming, naming) {
+		return fmt.Errorf("the prepared %s was constructed with another naming strategy than the client's", p.op)
+	}
+	return nil
+}
+
+func sameNamingStrategy(a, b ident.NamingStrategy) bool {
+	if a == nil {
+		a = ident.LowerCamelCase
+	}
+	if b == nil {
+		b = ident.LowerCamelCase
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// QueryPrepared executes the prepared query and populates the response into q,
+// which must have the type of the value the query was prepared with
+func (c *Client) QueryPrepared(ctx context.Context, p *PreparedQuery, q interface{}, variables interface{}) error {
+	return c.doPrepared(ctx, queryOperation, p, q, variables)
+}
+
+// MutatePrepared executes the prepared mutation and populates the response into m,
+// which must have the type of the value the mutation was prepared with
+func (c *Client) MutatePrepared(ctx context.Context, p *PreparedQuery, m interface{}, variables interface{}) error {
+	return c.doPrepared(ctx, mutationOperation, p, m, variables)
+}
+
+func (c *Client) doPrepared(ctx context.Context, op operationType, p *PreparedQuery, v interface{}, variables interface{}) error {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return Errors{newError(ErrGraphQLEncode, err)}
+	}
+	if err := p.check(op, v, vars, c.naming); err != nil {
+		return Errors{newError(ErrGraphQLEncode, err)}
+	}
+	data, resp, respBuf, errs := c.request(ctx, p.query, vars, "")
+	return c.processResponse(v, data, resp, respBuf, errs)
+}
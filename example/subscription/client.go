@@ -45,7 +45,7 @@ func startSubscription() error {
 		} `graphql:"helloSaid"`
 	}
 
-	subId, err := client.Subscribe(sub, nil, func(data []byte, err error) error {
+	subscription, err := client.Subscribe(sub, nil, func(data []byte, err error) error {
 
 		if err != nil {
 			log.Println(err)
@@ -66,7 +66,7 @@ func startSubscription() error {
 	// automatically unsubscribe after 10 seconds
 	go func() {
 		time.Sleep(10 * time.Second)
-		client.Unsubscribe(subId)
+		subscription.Unsubscribe()
 	}()
 
 	return client.Run()
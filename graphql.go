@@ -5,12 +5,14 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
+	"github.com/hasura/go-graphql-client/ident"
 	"github.com/hasura/go-graphql-client/pkg/jsonutil"
 )
 
@@ -30,6 +32,8 @@ type Client struct {
 	httpClient      Doer
 	requestModifier RequestModifier
 	debug           bool
+	decodeMode      DecodeMode
+	naming          ident.NamingStrategy
 }
 
 // NewClient creates a GraphQL client targeting the specified GraphQL server URL.
@@ -48,28 +52,28 @@ func NewClient(url string, httpClient Doer) *Client {
 // Query executes a single GraphQL query request,
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Query(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) Query(ctx context.Context, q interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQuery executes a single GraphQL query request, with operation name
 //
 // Deprecated: this is the shortcut of Query method, with NewOperationName option
-func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
 // Mutate executes a single GraphQL mutation request,
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Mutate(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) Mutate(ctx context.Context, m interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutate executes a single GraphQL mutation request, with operation name
 //
 // Deprecated: this is the shortcut of Mutate method, with NewOperationName option
-func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
@@ -77,13 +81,13 @@ func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, va
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) ([]byte, error) {
+func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables interface{}, options ...Option) ([]byte, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQueryRaw executes a single GraphQL query request, with operation name
 // return raw bytes message.
-func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) ([]byte, error) {
+func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) ([]byte, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
@@ -91,42 +95,43 @@ func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{},
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) ([]byte, error) {
+func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables interface{}, options ...Option) ([]byte, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutateRaw executes a single GraphQL mutation request, with operation name
 // return raw bytes message.
-func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) ([]byte, error) {
+func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) ([]byte, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
 // buildAndRequest the common method that builds and send graphql request
-func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) ([]byte, *http.Response, io.Reader, Errors) {
-	var query string
-	var err error
-	switch op {
-	case queryOperation:
-		query, err = ConstructQuery(v, variables, options...)
-	case mutationOperation:
-		query, err = ConstructMutation(v, variables, options...)
+func (c *Client) buildAndRequest(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) ([]byte, *http.Response, io.Reader, Errors) {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
 	}
-
+	query, _, err := constructOperation(op, v, vars, options, c.naming)
 	if err != nil {
 		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
 	}
 
-	return c.request(ctx, query, variables, options...)
+	return c.request(ctx, query, vars, "")
 }
 
 // Request the common method that send graphql request
-func (c *Client) request(ctx context.Context, query string, variables map[string]interface{}, options ...Option) ([]byte, *http.Response, io.Reader, Errors) {
+func (c *Client) request(ctx context.Context, query string, variables interface{}, operationName string) ([]byte, *http.Response, io.Reader, Errors) {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
+	}
 	in := GraphQLRequestPayload{
-		Query:     query,
-		Variables: variables,
+		Query:         query,
+		Variables:     encodeVariables(vars),
+		OperationName: operationName,
 	}
 	var buf bytes.Buffer
-	err := json.NewEncoder(&buf).Encode(in)
+	err = json.NewEncoder(&buf).Encode(in)
 	if err != nil {
 		return nil, nil, nil, Errors{newError(ErrGraphQLEncode, err)}
 	}
@@ -233,7 +238,7 @@ func (c *Client) request(ctx context.Context, query string, variables map[string
 
 // do executes a single GraphQL operation.
 // return raw message and error
-func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) ([]byte, error) {
+func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) ([]byte, error) {
 	data, _, _, err := c.buildAndRequest(ctx, op, v, variables, options...)
 	if len(err) > 0 {
 		return data, err
@@ -242,22 +247,32 @@ func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, var
 }
 
 // do executes a single GraphQL operation and unmarshal json.
-func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) error {
 	data, resp, respBuf, errs := c.buildAndRequest(ctx, op, v, variables, options...)
 	return c.processResponse(v, data, resp, respBuf, errs)
 }
 
 // Executes a pre-built query and unmarshals the response into v. Unlike the Query method you have to specify in the query the
 // fields that you want to receive as they are not inferred from v. This method is useful if you need to build the query dynamically.
-func (c *Client) Exec(ctx context.Context, query string, v interface{}, variables map[string]interface{}, options ...Option) error {
-	data, resp, respBuf, errs := c.request(ctx, query, variables, options...)
+// The query is parsed before sending, so malformed documents are rejected without a network round-trip.
+// The operation name is filled from the OperationName option, or from the document if it contains a single named operation.
+func (c *Client) Exec(ctx context.Context, query string, v interface{}, variables interface{}, options ...Option) error {
+	operationName, err := parseExecOperationName(query, false, options)
+	if err != nil {
+		return Errors{newError(ErrGraphQLEncode, err)}
+	}
+	data, resp, respBuf, errs := c.request(ctx, query, variables, operationName)
 	return c.processResponse(v, data, resp, respBuf, errs)
 }
 
 // Executes a pre-built query and returns the raw json message. Unlike the Query method you have to specify in the query the
 // fields that you want to receive as they are not inferred from the interface. This method is useful if you need to build the query dynamically.
-func (c *Client) ExecRaw(ctx context.Context, query string, variables map[string]interface{}, options ...Option) ([]byte, error) {
-	data, _, _, errs := c.request(ctx, query, variables, options...)
+func (c *Client) ExecRaw(ctx context.Context, query string, variables interface{}, options ...Option) ([]byte, error) {
+	operationName, err := parseExecOperationName(query, false, options)
+	if err != nil {
+		return nil, Errors{newError(ErrGraphQLEncode, err)}
+	}
+	data, _, _, errs := c.request(ctx, query, variables, operationName)
 	if len(errs) > 0 {
 		return data, errs
 	}
@@ -266,9 +281,9 @@ func (c *Client) ExecRaw(ctx context.Context, query string, variables map[string
 
 func (c *Client) processResponse(v interface{}, data []byte, resp *http.Response, respBuf io.Reader, errs Errors) error {
 	if len(data) > 0 {
-		err := jsonutil.UnmarshalGraphQL(data, v)
+		err := jsonutil.UnmarshalGraphQL(data, v, c.decoderOptions()...)
 		if err != nil {
-			we := newError(ErrGraphQLDecode, err)
+			we := newDecodeError(err)
 			if c.debug {
 				we = we.withResponse(resp, respBuf)
 			}
@@ -283,6 +298,14 @@ func (c *Client) processResponse(v interface{}, data []byte, resp *http.Response
 	return nil
 }
 
+// decoderOptions returns the options of decoding responses into query structs
+func (c *Client) decoderOptions() []jsonutil.DecoderOption {
+	return []jsonutil.DecoderOption{
+		jsonutil.WithDecodeMode(c.decodeMode),
+		jsonutil.WithNamingStrategy(c.naming),
+	}
+}
+
 // Returns a copy of the client with the request modifier set. This allows you to reuse the same
 // TCP connection for multiple slightly different requests to the same server
 // (i.e. different authentication headers for multitenant applications)
@@ -291,6 +314,8 @@ func (c *Client) WithRequestModifier(f RequestModifier) *Client {
 		url:             c.url,
 		httpClient:      c.httpClient,
 		requestModifier: f,
+		decodeMode:      c.decodeMode,
+		naming:          c.naming,
 	}
 }
 
@@ -301,6 +326,36 @@ func (c *Client) WithDebug(debug bool) *Client {
 		httpClient:      c.httpClient,
 		requestModifier: c.requestModifier,
 		debug:           debug,
+		decodeMode:      c.decodeMode,
+		naming:          c.naming,
+	}
+}
+
+// WithDecodeMode returns a copy of the client with the decode mode of responses set.
+// DecodeModeLenient ignores response fields that don't exist in the query struct,
+// DecodeModeStrict reports selected fields that are missing from the response and null values of non-pointer fields
+func (c *Client) WithDecodeMode(mode DecodeMode) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		decodeMode:      mode,
+		naming:          c.naming,
+	}
+}
+
+// WithNamingStrategy returns a copy of the client with the naming strategy of struct fields without graphql tag set.
+// The strategy names the fields of constructed queries, and the response keys are decoded into fields by the same names.
+// The default is ident.LowerCamelCase
+func (c *Client) WithNamingStrategy(naming ident.NamingStrategy) *Client {
+	return &Client{
+		url:             c.url,
+		httpClient:      c.httpClient,
+		requestModifier: c.requestModifier,
+		debug:           c.debug,
+		decodeMode:      c.decodeMode,
+		naming:          naming,
 	}
 }
 
@@ -317,6 +372,10 @@ type Error struct {
 		Line   int `json:"line"`
 		Column int `json:"column"`
 	} `json:"locations"`
+	// Path is the path of the response field that the error belongs to, if any
+	Path []interface{} `json:"path,omitempty"`
+	// err is the underlying error of errors created by the client
+	err error
 }
 
 // Error implements error interface.
@@ -324,6 +383,12 @@ func (e Error) Error() string {
 	return fmt.Sprintf("Message: %s, Locations: %+v, Extensions: %+v", e.Message, e.Locations, e.Extensions)
 }
 
+// Unwrap returns the underlying error of errors created by the client, e.g. *DecodeError.
+// It returns nil for errors of the GraphQL response
+func (e Error) Unwrap() error {
+	return e.err
+}
+
 // Error implements error interface.
 func (e Errors) Error() string {
 	b := strings.Builder{}
@@ -351,9 +416,24 @@ func newError(code string, err error) Error {
 		Extensions: map[string]interface{}{
 			"code": code,
 		},
+		err: err,
 	}
 }
 
+// newDecodeError creates an ErrGraphQLDecode error.
+// The paths of the value that failed to decode are added to extensions
+func newDecodeError(err error) Error {
+	e := newError(ErrGraphQLDecode, err)
+	var decodeErr *jsonutil.DecodeError
+	if errors.As(err, &decodeErr) && decodeErr.JSONPath != "" {
+		e.Extensions["path"] = decodeErr.JSONPath
+		if decodeErr.FieldPath != "" {
+			e.Extensions["field_path"] = decodeErr.FieldPath
+		}
+	}
+	return e
+}
+
 func (e Error) withRequest(req *http.Request, bodyReader io.Reader) Error {
 	internal := e.getInternalExtension()
 	bodyBytes, err := ioutil.ReadAll(bodyReader)
@@ -395,16 +475,92 @@ func (e Error) withResponse(res *http.Response, bodyReader io.Reader) Error {
 // The implementation is created on top of the JSON tokenizer available
 // in "encoding/json".Decoder.
 // This function is re-exported from the internal package
-func UnmarshalGraphQL(data []byte, v interface{}) error {
-	return jsonutil.UnmarshalGraphQL(data, v)
+func UnmarshalGraphQL(data []byte, v interface{}, options ...DecoderOption) error {
+	return jsonutil.UnmarshalGraphQL(data, v, options...)
+}
+
+// DecodeError is returned when the response data can't be decoded into the query,
+// with the JSON path of the value and the matching path of Go fields.
+// This type is re-exported from the internal package
+type DecodeError = jsonutil.DecodeError
+
+// DecodeMode controls how differences between the response data and the query struct are handled.
+// This type is re-exported from the internal package
+type DecodeMode = jsonutil.DecodeMode
+
+const (
+	// DecodeModeDefault fails on response keys that don't exist in the struct,
+	// and ignores selected fields that are missing from the response
+	DecodeModeDefault = jsonutil.DecodeModeDefault
+	// DecodeModeLenient ignores response keys that don't exist in the struct
+	DecodeModeLenient = jsonutil.DecodeModeLenient
+	// DecodeModeStrict fails on response keys that don't exist in the struct,
+	// selected struct fields that are missing from the response, and null values of non-pointer fields
+	DecodeModeStrict = jsonutil.DecodeModeStrict
+)
+
+// DecoderOption configures the behavior of UnmarshalGraphQL.
+// This type is re-exported from the internal package
+type DecoderOption = jsonutil.DecoderOption
+
+// WithDecodeMode sets the decode mode of UnmarshalGraphQL.
+// This function is re-exported from the internal package
+func WithDecodeMode(mode DecodeMode) DecoderOption {
+	return jsonutil.WithDecodeMode(mode)
+}
+
+// WithNamingStrategy sets the naming strategy of struct fields without graphql tag,
+// which must be the strategy the query was constructed with. The default is ident.LowerCamelCase.
+// This function is re-exported from the internal package
+func WithNamingStrategy(naming ident.NamingStrategy) DecoderOption {
+	return jsonutil.WithNamingStrategy(naming)
+}
+
+// ScalarConfig describes how values of a Go type are named, encoded and decoded as a GraphQL scalar.
+// This type is re-exported from the internal package
+type ScalarConfig = jsonutil.ScalarConfig
+
+// RegisterScalar registers the type of example as the GraphQL scalar of config, e.g. time.Time as timestamptz,
+// so types that can't implement GraphQLType or json.Unmarshaler work as variables and result fields.
+// Pointers to the type are the nullable scalar. If example is a pointer, its element type is registered.
+// This function is re-exported from the internal package
+func RegisterScalar(example interface{}, config ScalarConfig) error {
+	return jsonutil.RegisterScalar(example, config)
+}
+
+// UnionMember is a concrete Go type of a GraphQL union or interface.
+// This type is re-exported from the internal package
+type UnionMember = jsonutil.UnionMember
+
+// RegisterUnion registers concrete Go types of the GraphQL union or interface that is represented by a Go interface.
+// iface must be a nil pointer to the interface, e.g. (*SearchResult)(nil).
+// types maps __typename to an example value of the concrete type, e.g. {"User": User{}, "Repository": &Repository{}}.
+//
+// Fields of the interface type select __typename and an inline fragment per registered type,
+// and they are decoded into the concrete type that matches __typename.
+// This function is re-exported from the internal package
+func RegisterUnion(iface interface{}, types map[string]interface{}) error {
+	return jsonutil.RegisterUnion(iface, types)
 }
 
 type operationType uint8
 
+// String returns the keyword of the operation type
+func (op operationType) String() string {
+	switch op {
+	case mutationOperation:
+		return "mutation"
+	case subscriptionOperation:
+		return "subscription"
+	default:
+		return "query"
+	}
+}
+
 const (
 	queryOperation operationType = iota
 	mutationOperation
-	// subscriptionOperation // Unused.
+	subscriptionOperation
 
 	ErrRequestError  = "request_error"
 	ErrJsonEncode    = "json_encode_error"
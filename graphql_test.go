@@ -8,9 +8,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hasura/go-graphql-client"
+	"github.com/hasura/go-graphql-client/ident"
 )
 
 func TestClient_Query_partialDataWithErrorResponse(t *testing.T) {
@@ -414,6 +417,399 @@ func TestClient_Exec_QueryRaw(t *testing.T) {
 	}
 }
 
+// Test exec pre-built query, the operation name is filled from the document
+func TestClient_Exec_OperationName(t *testing.T) {
+	fixtures := []struct {
+		query   string
+		options []graphql.Option
+		want    string
+	}{
+		{
+			query: "query GetUser{user{id,name}}",
+			want:  `{"query":"query GetUser{user{id,name}}","operationName":"GetUser"}` + "\n",
+		},
+		{
+			query:   "query GetUser{user{id,name}} query GetViewer{viewer{id,name}}",
+			options: []graphql.Option{graphql.OperationName("GetViewer")},
+			want:    `{"query":"query GetUser{user{id,name}} query GetViewer{viewer{id,name}}","operationName":"GetViewer"}` + "\n",
+		},
+	}
+
+	for _, f := range fixtures {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+			body := mustRead(req.Body)
+			if got, want := body, f.want; got != want {
+				t.Errorf("got body: %v, want %v", got, want)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+		})
+		client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+		if _, err := client.ExecRaw(context.Background(), f.query, nil, f.options...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test exec pre-built query, malformed documents are rejected before sending the request
+func TestClient_Exec_InvalidQuery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		t.Error("unexpected request")
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	fixtures := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "{user{id,name}",
+			want:  "Message: graphql syntax error (1:15): expected name, got <EOF>, Locations: [], Extensions: map[code:graphql_encode_error]",
+		},
+		{
+			query: "query A{user{id}} query B{viewer{id}}",
+			want:  "Message: the operation name is required because the document contains 2 operations, Locations: [], Extensions: map[code:graphql_encode_error]",
+		},
+		{
+			query: "subscription{user{id}}",
+			want:  "Message: subscription operations must be executed by the subscription client, Locations: [], Extensions: map[code:graphql_encode_error]",
+		},
+	}
+
+	for _, f := range fixtures {
+		var q struct {
+			User struct {
+				ID string
+			}
+		}
+		err := client.Exec(context.Background(), f.query, &q, nil)
+		if err == nil {
+			t.Fatalf("%s: expected error, got nil", f.query)
+		}
+		if got := err.Error(); got != f.want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", f.query, got, f.want)
+		}
+	}
+}
+
+func TestClient_MergedQuery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($q0_id:ID!$q1_id:ID!){q0_user:user(id:$q0_id){name},q1_user:user(id:$q1_id){name}}","variables":{"q0_id":"1","q1_id":"2"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"q0_user": {"name": "Gopher"}, "q1_user": null}, "errors": [{"message": "user 2 not found", "path": ["q1_user"]}, {"message": "rate limited"}]}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type userQuery struct {
+		User *struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	var first, second userQuery
+	items := []graphql.MergeItem{
+		{Query: &first, Variables: map[string]interface{}{"id": graphql.ID("1")}},
+		{Query: &second, Variables: map[string]interface{}{"id": graphql.ID("2")}},
+	}
+	err := client.MergedQuery(context.Background(), items)
+	if err == nil || err.Error() != "Message: user 2 not found, Locations: [], Extensions: map[]Message: rate limited, Locations: [], Extensions: map[]" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the errors of root fields are routed to their items, without the alias prefix in their paths
+	if len(items[0].Errors) != 0 {
+		t.Errorf("got errors of the first item: %v, want none", items[0].Errors)
+	}
+	if errs := items[1].Errors; len(errs) != 1 || errs[0].Message != "user 2 not found" || len(errs[0].Path) != 1 || errs[0].Path[0] != "user" {
+		t.Errorf("got errors of the second item: %+v, want user 2 not found at user", errs)
+	}
+	if first.User == nil || first.User.Name != "Gopher" {
+		t.Errorf("got first user: %+v, want Gopher", first.User)
+	}
+	if second.User != nil {
+		t.Errorf("got second user: %+v, want nil", second.User)
+	}
+}
+
+func TestClient_QueryDynamic(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($login:String!){user(login:$login){name,followers{totalCount}}}","variables":{"login":"gopher"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher", "followers": {"totalCount": 42}}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	q := graphql.NewQueryBuilder()
+	user := q.Field("user").Arg("login", graphql.Var("login"))
+	user.Fields("name")
+	user.Field("followers").Fields("totalCount")
+
+	got, err := client.QueryDynamic(context.Background(), q, map[string]interface{}{"login": "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":      "Gopher",
+			"followers": map[string]interface{}{"totalCount": json.Number("42")},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_WithNamingStrategy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"{current_user{user_id,display_name}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"current_user": {"user_id": "1", "display_name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithNamingStrategy(ident.SnakeCase)
+
+	type query struct {
+		CurrentUser struct {
+			UserID      string
+			DisplayName string
+		}
+	}
+	var q query
+	if err := client.WithDecodeMode(graphql.DecodeModeStrict).Query(context.Background(), &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.CurrentUser.DisplayName, "Gopher"; got != want {
+		t.Errorf("got q.CurrentUser.DisplayName: %q, want: %q", got, want)
+	}
+
+	prepared, err := client.PrepareQuery(&query{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.QueryPrepared(context.Background(), prepared, &q, nil); err != nil {
+		t.Error(err)
+	}
+	defaultPrepared, err := graphql.PrepareQuery(&query{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Message: the prepared query was constructed with another naming strategy than the client's, Locations: [], Extensions: map[code:graphql_encode_error]"
+	if err := client.QueryPrepared(context.Background(), defaultPrepared, &q, nil); err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
+
+func TestClient_Query_variablesStruct(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($first:Int=10$login:String!){user(login: $login){repositories(first: $first){name}}}","variables":{"first":5,"login":"gopher"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"repositories": [{"name": "go"}]}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Repositories []struct {
+				Name string
+			} `graphql:"repositories(first: $first)"`
+		} `graphql:"user(login: $login)"`
+	}
+	variables := struct {
+		Login string
+		First int `graphql:"first,nullable" default:"10"`
+	}{Login: "gopher", First: 5}
+	if err := client.Query(context.Background(), &q, variables); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(q.User.Repositories), 1; got != want {
+		t.Errorf("got len(q.User.Repositories): %v, want: %v", got, want)
+	}
+}
+
+func TestClient_QueryPrepared(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query GetUser($login:String!){user(login: $login){name}}","variables":{"login":"gopher"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type query struct {
+		User struct {
+			Name string
+		} `graphql:"user(login: $login)"`
+	}
+	prepared, err := graphql.PrepareQuery(&query{}, map[string]interface{}{"login": ""}, graphql.OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := prepared.String(), `query GetUser($login:String!){user(login: $login){name}}`; got != want {
+		t.Errorf("got document: %q, want: %q", got, want)
+	}
+
+	var q query
+	err = client.QueryPrepared(context.Background(), prepared, &q, map[string]interface{}{"login": "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+
+	fixtures := []struct {
+		v         interface{}
+		variables map[string]interface{}
+		want      string
+	}{
+		{
+			v:         &struct{ Viewer struct{ Login string } }{},
+			variables: map[string]interface{}{"login": "gopher"},
+			want:      "Message: the prepared query expects *graphql_test.query, got *struct { Viewer struct { Login string } }, Locations: [], Extensions: map[code:graphql_encode_error]",
+		},
+		{
+			v:         &q,
+			variables: map[string]interface{}{"login": 1},
+			want:      "Message: the variables don't match the names and types of the prepared query, Locations: [], Extensions: map[code:graphql_encode_error]",
+		},
+	}
+	for _, f := range fixtures {
+		err := client.QueryPrepared(context.Background(), prepared, f.v, f.variables)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("got error: %v, want: %s", err, f.want)
+		}
+	}
+	if err := client.MutatePrepared(context.Background(), prepared, &q, map[string]interface{}{"login": "gopher"}); err == nil {
+		t.Error("got no error executing a prepared query as a mutation")
+	}
+}
+
+func TestClient_Query_map(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"{user{name,stats{followers}},viewer{login}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher", "stats": {"followers": 42}}, "viewer": {"login": "gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	viewer := graphql.NewQueryBuilder()
+	viewer.Fields("login")
+	m := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  nil,
+			"stats": map[string]interface{}{"followers": nil},
+		},
+		"viewer": viewer,
+	}
+	if err := client.Query(context.Background(), &m, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "Gopher",
+			"stats": map[string]interface{}{"followers": json.Number("42")},
+		},
+		"viewer": map[string]interface{}{"login": "gopher"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got: %v, want: %v", m, want)
+	}
+}
+
+func TestClient_WithDecodeMode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"id": "1", "name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	err := client.Exec(context.Background(), "{user{id,name}}", &q, nil)
+	if err == nil || err.Error() != `Message: failed to decode user.id into User: struct field for "id" doesn't exist in any of 1 places to unmarshal, Locations: [], Extensions: map[code:graphql_decode_error field_path:User path:user.id]` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := client.WithDecodeMode(graphql.DecodeModeLenient).Exec(context.Background(), "{user{id,name}}", &q, nil); err != nil {
+		t.Fatal(err)
+	}
+	if q.User.Name != "Gopher" {
+		t.Errorf("got q.User.Name: %q, want: %q", q.User.Name, "Gopher")
+	}
+
+	var strict struct {
+		User struct {
+			ID    string
+			Name  string
+			Email string
+		}
+	}
+	err = client.WithDecodeMode(graphql.DecodeModeStrict).Exec(context.Background(), "{user{id,name}}", &strict, nil)
+	if err == nil || !strings.Contains(err.Error(), `selected field "Email"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_Query_decodeErrorPath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"repositories": [{"stars": 1}, {"stars": "many"}]}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Repositories []struct {
+				Stars int
+			}
+		}
+	}
+	err := client.Query(context.Background(), &q, nil)
+	var errs graphql.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error: %v, want graphql.Errors", err)
+	}
+	if got, want := errs[0].Extensions["path"], "user.repositories[1].stars"; got != want {
+		t.Errorf("got path: %v, want: %v", got, want)
+	}
+	if got, want := errs[0].Extensions["field_path"], "User.Repositories[1].Stars"; got != want {
+		t.Errorf("got field path: %v, want: %v", got, want)
+	}
+	var decodeErr *graphql.DecodeError
+	if !errors.As(errs[0], &decodeErr) || decodeErr.Err.Error() != "json: cannot unmarshal string into Go value of type int" {
+		t.Errorf("got error: %v, want DecodeError", errs[0])
+	}
+}
+
 // localRoundTripper is an http.RoundTripper that executes HTTP transactions
 // by using handler directly, instead of going over an HTTP connection.
 type localRoundTripper struct {
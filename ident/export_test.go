@@ -0,0 +1,4 @@
+package ident
+
+// SaveWords exports saveWords to the tests of package ident_test.
+var SaveWords = saveWords
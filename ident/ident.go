@@ -1,14 +1,52 @@
 // Package ident provides functions for parsing and converting identifier names
 // between various naming convention. It has support for MixedCaps, lowerCamelCase,
-// and SCREAMING_SNAKE_CASE naming conventions.
+// snake_case and SCREAMING_SNAKE_CASE naming conventions.
 package ident
 
 import (
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
 
+// NamingStrategy converts the name of a Go struct field to the name of the GraphQL field,
+// for struct fields without graphql tag.
+//
+// Names are cached per struct type and strategy if the strategy is of a comparable type,
+// e.g. a struct type without func fields.
+type NamingStrategy interface {
+	FieldName(goName string) string
+}
+
+var (
+	// LowerCamelCase names fields in lowerCamelCase, e.g. "ClientMutationID" -> "clientMutationId".
+	// It's the default strategy.
+	LowerCamelCase NamingStrategy = lowerCamelCaseStrategy{}
+	// SnakeCase names fields in snake_case, e.g. "ClientMutationID" -> "client_mutation_id".
+	SnakeCase NamingStrategy = snakeCaseStrategy{}
+	// ScreamingSnakeCase names fields in SCREAMING_SNAKE_CASE, e.g. "ClientMutationID" -> "CLIENT_MUTATION_ID".
+	ScreamingSnakeCase NamingStrategy = screamingSnakeCaseStrategy{}
+)
+
+type lowerCamelCaseStrategy struct{}
+
+func (lowerCamelCaseStrategy) FieldName(name string) string {
+	return ParseMixedCaps(name).ToLowerCamelCase()
+}
+
+type snakeCaseStrategy struct{}
+
+func (snakeCaseStrategy) FieldName(name string) string {
+	return ParseMixedCaps(name).ToSnakeCase()
+}
+
+type screamingSnakeCaseStrategy struct{}
+
+func (screamingSnakeCaseStrategy) FieldName(name string) string {
+	return ParseMixedCaps(name).ToScreamingSnakeCase()
+}
+
 // ParseMixedCaps parses a MixedCaps identifier name.
 //
 // E.g., "ClientMutationID" -> {"Client", "Mutation", "ID"}.
@@ -156,16 +194,86 @@ func (n Name) ToLowerCamelCase() string {
 	return strings.Join(n, "")
 }
 
+// ToSnakeCase expresses identifier name in snake_case naming convention.
+//
+// E.g., "client_mutation_id".
+func (n Name) ToSnakeCase() string {
+	words := make([]string, len(n))
+	for i, word := range n {
+		words[i] = strings.ToLower(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// ToScreamingSnakeCase expresses identifier name in SCREAMING_SNAKE_CASE naming convention.
+//
+// E.g., "CLIENT_MUTATION_ID".
+func (n Name) ToScreamingSnakeCase() string {
+	words := make([]string, len(n))
+	for i, word := range n {
+		words[i] = strings.ToUpper(word)
+	}
+	return strings.Join(words, "_")
+}
+
+// wordsMu guards initialisms and brands, which can be extended at runtime.
+var wordsMu sync.RWMutex
+
+// RegisterInitialisms adds initialisms in the MixedCaps naming convention, e.g. "SKU".
+// Names that are cached already aren't updated, so register initialisms before constructing queries, e.g. in init.
+func RegisterInitialisms(words ...string) {
+	wordsMu.Lock()
+	defer wordsMu.Unlock()
+	for _, word := range words {
+		initialisms[strings.ToUpper(word)] = struct{}{}
+	}
+}
+
+// RegisterBrands adds brands in their canonical spelling, e.g. "GitLab".
+// Names that are cached already aren't updated, so register brands before constructing queries, e.g. in init.
+func RegisterBrands(brandNames ...string) {
+	wordsMu.Lock()
+	defer wordsMu.Unlock()
+	for _, brand := range brandNames {
+		brands[strings.ToLower(brand)] = brand
+	}
+}
+
+// saveWords copies initialisms and brands, and returns the function that restores them,
+// so tests can register words without leaking them into other tests.
+func saveWords() (restore func()) {
+	wordsMu.RLock()
+	defer wordsMu.RUnlock()
+	savedInitialisms := make(map[string]struct{}, len(initialisms))
+	for word := range initialisms {
+		savedInitialisms[word] = struct{}{}
+	}
+	savedBrands := make(map[string]string, len(brands))
+	for word, brand := range brands {
+		savedBrands[word] = brand
+	}
+	return func() {
+		wordsMu.Lock()
+		defer wordsMu.Unlock()
+		initialisms = savedInitialisms
+		brands = savedBrands
+	}
+}
+
 // isInitialism reports whether word is an initialism.
 func isInitialism(word string) (string, bool) {
 	initialism := strings.ToUpper(word)
+	wordsMu.RLock()
 	_, ok := initialisms[initialism]
+	wordsMu.RUnlock()
 	return initialism, ok
 }
 
 // isTwoInitialisms reports whether word is two initialisms.
 func isTwoInitialisms(word string) (string, string, bool) {
 	word = strings.ToUpper(word)
+	wordsMu.RLock()
+	defer wordsMu.RUnlock()
 	for i := 2; i <= len(word)-2; i++ { // Shortest initialism is 2 characters long.
 		_, ok1 := initialisms[word[:i]]
 		_, ok2 := initialisms[word[i:]]
@@ -227,7 +335,9 @@ var initialisms = map[string]struct{}{
 
 // isBrand reports whether word is a brand.
 func isBrand(word string) (string, bool) {
+	wordsMu.RLock()
 	brand, ok := brands[strings.ToLower(word)]
+	wordsMu.RUnlock()
 	return brand, ok
 }
 
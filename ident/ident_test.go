@@ -163,3 +163,55 @@ func TestLowerCamelCaseToMixedCaps(t *testing.T) {
 		}
 	}
 }
+
+func Example_mixedCapsToSnakeCase() {
+	fmt.Println(ident.ParseMixedCaps("ClientMutationID").ToSnakeCase())
+
+	// Output: client_mutation_id
+}
+
+func TestNamingStrategy(t *testing.T) {
+	tests := []struct {
+		in         string
+		lowerCamel string
+		snake      string
+		screaming  string
+	}{
+		{in: "DatabaseID", lowerCamel: "databaseId", snake: "database_id", screaming: "DATABASE_ID"},
+		{in: "CreatedAt", lowerCamel: "createdAt", snake: "created_at", screaming: "CREATED_AT"},
+		{in: "URL", lowerCamel: "url", snake: "url", screaming: "URL"},
+		{in: "UserIDs", lowerCamel: "userIds", snake: "user_ids", screaming: "USER_IDS"},
+		{in: "HTTPServerURL", lowerCamel: "httpServerUrl", snake: "http_server_url", screaming: "HTTP_SERVER_URL"},
+	}
+	for _, tc := range tests {
+		got := []string{ident.LowerCamelCase.FieldName(tc.in), ident.SnakeCase.FieldName(tc.in), ident.ScreamingSnakeCase.FieldName(tc.in)}
+		want := []string{tc.lowerCamel, tc.snake, tc.screaming}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got: %q, want: %q", tc.in, got, want)
+		}
+	}
+}
+
+func TestRegisterInitialisms(t *testing.T) {
+	t.Cleanup(ident.SaveWords())
+
+	if got, want := ident.ParseLowerCamelCase("productSku").ToMixedCaps(), "ProductSku"; got != want {
+		t.Fatalf("before registering: got: %q, want: %q", got, want)
+	}
+	if got, want := ident.ParseLowerCamelCase("gitlabProject").ToMixedCaps(), "GitlabProject"; got != want {
+		t.Fatalf("before registering: got: %q, want: %q", got, want)
+	}
+
+	ident.RegisterInitialisms("SKU")
+	if got, want := ident.ParseMixedCaps("ProductSKU").ToSnakeCase(), "product_sku"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	if got, want := ident.ParseLowerCamelCase("productSku").ToMixedCaps(), "ProductSKU"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	ident.RegisterBrands("GitLab")
+	if got, want := ident.ParseLowerCamelCase("gitlabProject").ToMixedCaps(), "GitLabProject"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
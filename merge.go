@@ -0,0 +1,314 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
+	"github.com/hasura/go-graphql-client/pkg/parser"
+)
+
+// MergeItem is a struct query and its variables that is merged with other items into a single operation
+type MergeItem struct {
+	// Query is the struct that describes the selection set, the same as the input of the Query method.
+	// It must be a pointer to be decoded by MergedQuery and MergedMutate
+	Query interface{}
+	// Variables is a map or a struct of variables, the same as the variables of the Query method
+	Variables interface{}
+	// Errors are the errors of the response whose path starts with a root field of the item,
+	// which are set by MergedQuery and MergedMutate. Their paths start with the response key of the item, without the prefix
+	Errors Errors
+}
+
+// mergedOperation is the result of merging many items into one document
+type mergedOperation struct {
+	query     string
+	variables map[string]interface{}
+	// aliases maps the response keys of the merged document to the root fields of items
+	aliases map[string]mergedField
+}
+
+type mergedField struct {
+	index int
+	key   string
+}
+
+// ConstructMergedQuery merges many struct queries into a single GraphQL query.
+// The root fields of each item are aliased with the q<index>_ prefix and variables are renamed with the same prefix,
+// so the same field can be requested many times with different arguments.
+// It returns the query and the merged variables
+func ConstructMergedQuery(items []MergeItem, options ...Option) (string, map[string]interface{}, error) {
+	merged, err := mergeOperations(queryOperation, items, options, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return merged.query, merged.variables, nil
+}
+
+// ConstructMergedMutation merges many struct mutations into a single GraphQL mutation.
+// See ConstructMergedQuery for details
+func ConstructMergedMutation(items []MergeItem, options ...Option) (string, map[string]interface{}, error) {
+	merged, err := mergeOperations(mutationOperation, items, options, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return merged.query, merged.variables, nil
+}
+
+// MergedQuery executes many struct queries in a single GraphQL query against the server.
+// The response is split back and decoded into the Query field of each item, and the errors of each item
+// are set to its Errors field. Unlike HTTP batching, it works with any GraphQL server
+func (c *Client) MergedQuery(ctx context.Context, items []MergeItem, options ...Option) error {
+	return c.doMerged(ctx, queryOperation, items, options)
+}
+
+// MergedMutate executes many struct mutations in a single GraphQL mutation against the server.
+// The response is split back and decoded into the Query field of each item
+func (c *Client) MergedMutate(ctx context.Context, items []MergeItem, options ...Option) error {
+	return c.doMerged(ctx, mutationOperation, items, options)
+}
+
+func (c *Client) doMerged(ctx context.Context, op operationType, items []MergeItem, options []Option) error {
+	merged, err := mergeOperations(op, items, options, c.naming)
+	if err != nil {
+		return Errors{newError(ErrGraphQLEncode, err)}
+	}
+
+	data, resp, respBuf, errs := c.request(ctx, merged.query, merged.variables, "")
+	merged.routeErrors(errs, items)
+	if len(data) > 0 {
+		if err := merged.unmarshal(data, items, c.decoderOptions()); err != nil {
+			we := newDecodeError(err)
+			if c.debug {
+				we = we.withResponse(resp, respBuf)
+			}
+			errs = append(errs, we)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// mergeOperations builds the merged document of items.
+// Named fragments are shared by all items and defined once
+func mergeOperations(op operationType, items []MergeItem, options []Option, naming ident.NamingStrategy) (*mergedOperation, error) {
+	if len(items) == 0 {
+		return nil, errors.New("no operation to merge")
+	}
+	optionsOutput, err := constructOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &mergedOperation{
+		variables: map[string]interface{}{},
+		aliases:   map[string]mergedField{},
+	}
+	qw := &queryWriter{naming: naming}
+	var selections parser.SelectionSet
+	for i, item := range items {
+		var buf bytes.Buffer
+		if err := qw.writeQuery(&buf, reflect.TypeOf(item.Query), reflect.ValueOf(item.Query), false); err != nil {
+			return nil, fmt.Errorf("failed to write query %d: %w", i, err)
+		}
+		// the fragments defined so far are included, so spreads of this item are resolved
+		doc, err := parser.Parse(buf.String() + qw.fragmentsString())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse query %d: %w", i, err)
+		}
+
+		prefix := fmt.Sprintf("q%d_", i)
+		root := doc.Operations[0].SelectionSet
+		if err := merged.aliasRootFields(root, i, prefix); err != nil {
+			return nil, fmt.Errorf("query %d: %w", i, err)
+		}
+		renameSelectionVariables(root, prefix)
+		selections = append(selections, root...)
+
+		variables, err := variablesMap(item.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("variables of query %d: %w", i, err)
+		}
+		for name, value := range variables {
+			merged.variables[prefix+name] = value
+		}
+	}
+
+	// variables can't be renamed inside fragments that are shared by many items
+	for _, f := range qw.fragments {
+		doc, err := parser.Parse("fragment " + f.name + " on " + f.typeCondition + f.body)
+		if err != nil {
+			return nil, err
+		}
+		if names := selectionVariables(doc.Fragments[0].SelectionSet); len(names) > 0 {
+			return nil, fmt.Errorf("fragment %s uses variable $%s; variables in named fragments can't be merged", f.name, names[0])
+		}
+	}
+
+	merged.query = formatOperation(op, optionsOutput, merged.variables, selections.String(), qw.fragmentsString())
+	return merged, nil
+}
+
+// aliasRootFields prefixes the response keys of root fields and records their original keys
+func (m *mergedOperation) aliasRootFields(selections parser.SelectionSet, index int, prefix string) error {
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *parser.Field:
+			key := s.ResponseKey()
+			s.Alias = prefix + key
+			m.aliases[s.Alias] = mergedField{index: index, key: key}
+		case *parser.InlineFragment:
+			if err := m.aliasRootFields(s.SelectionSet, index, prefix); err != nil {
+				return err
+			}
+		case *parser.FragmentSpread:
+			return fmt.Errorf("named fragment %s can't be spread at the root of a merged operation", s.Name)
+		}
+	}
+	return nil
+}
+
+// unmarshal splits the response data by root field aliases and decodes each part into the matching item.
+// The fields are split in the order of the response, so ordered maps are decoded in the same order
+func (m *mergedOperation) unmarshal(data []byte, items []MergeItem, options []jsonutil.DecoderOption) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("unexpected data: %v", tok)
+	}
+	parts := make([]*bytes.Buffer, len(items))
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		target, ok := m.aliases[tok.(string)]
+		if !ok {
+			continue
+		}
+		part := parts[target.index]
+		if part == nil {
+			part = bytes.NewBufferString("{")
+			parts[target.index] = part
+		} else {
+			part.WriteByte(',')
+		}
+		key, err := json.Marshal(target.key)
+		if err != nil {
+			return err
+		}
+		part.Write(key)
+		part.WriteByte(':')
+		part.Write(value)
+	}
+	for i, part := range parts {
+		if part == nil {
+			continue
+		}
+		part.WriteByte('}')
+		if err := jsonutil.UnmarshalGraphQL(part.Bytes(), items[i].Query, options...); err != nil {
+			return fmt.Errorf("query %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// routeErrors sets the errors whose path starts with a root field alias to the matching item,
+// and replaces the alias in their paths with the original response key
+func (m *mergedOperation) routeErrors(errs Errors, items []MergeItem) {
+	for i := range items {
+		items[i].Errors = nil
+	}
+	for i, e := range errs {
+		if len(e.Path) == 0 {
+			continue
+		}
+		alias, ok := e.Path[0].(string)
+		if !ok {
+			continue
+		}
+		target, ok := m.aliases[alias]
+		if !ok {
+			continue
+		}
+		path := append([]interface{}{target.key}, e.Path[1:]...)
+		errs[i].Path = path
+		e.Path = path
+		items[target.index].Errors = append(items[target.index].Errors, e)
+	}
+}
+
+// renameSelectionVariables prefixes all variables referenced by arguments and directives of the selection set
+func renameSelectionVariables(selections parser.SelectionSet, prefix string) {
+	walkSelectionValues(selections, func(v *parser.Value) {
+		renameValueVariables(v, prefix)
+	})
+}
+
+func renameValueVariables(v *parser.Value, prefix string) {
+	switch v.Kind {
+	case parser.VariableValue:
+		v.Raw = prefix + v.Raw
+	case parser.ListValue:
+		for _, item := range v.List {
+			renameValueVariables(item, prefix)
+		}
+	case parser.ObjectValue:
+		for _, f := range v.Fields {
+			renameValueVariables(f.Value, prefix)
+		}
+	}
+}
+
+// selectionVariables returns names of variables referenced in the selection set
+func selectionVariables(selections parser.SelectionSet) []string {
+	var names []string
+	walkSelectionValues(selections, func(v *parser.Value) {
+		names = append(names, v.Variables()...)
+	})
+	return names
+}
+
+// walkSelectionValues calls fn for every argument value in the selection set recursively
+func walkSelectionValues(selections parser.SelectionSet, fn func(*parser.Value)) {
+	walkDirectives := func(directives []*parser.Directive) {
+		for _, d := range directives {
+			for _, arg := range d.Arguments {
+				fn(arg.Value)
+			}
+		}
+	}
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *parser.Field:
+			for _, arg := range s.Arguments {
+				fn(arg.Value)
+			}
+			walkDirectives(s.Directives)
+			walkSelectionValues(s.SelectionSet, fn)
+		case *parser.FragmentSpread:
+			walkDirectives(s.Directives)
+		case *parser.InlineFragment:
+			walkDirectives(s.Directives)
+			walkSelectionValues(s.SelectionSet, fn)
+		}
+	}
+}
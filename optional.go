@@ -0,0 +1,56 @@
+package graphql
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
+)
+
+// Optional is a value that is either omitted, explicitly null, or set.
+// The type of Optional variables is nullable, and the variables encoder writes null for null values
+// and leaves omitted values out of variables and input objects, e.g.
+//
+//	type usersSetInput struct {
+//		Name     graphql.Optional `json:"name"`
+//		Nickname graphql.Optional `json:"nickname"`
+//	}
+//
+//	usersSetInput{Name: graphql.NewOptional("Gopher"), Nickname: graphql.NullOptional("")}
+//
+// is encoded as {"name":"Gopher","nickname":null}.
+// This type is re-exported from the internal package
+type Optional = jsonutil.Optional
+
+// NewOptional returns the Optional set to v. Nil values, including nil pointers, are null.
+// This function is re-exported from the internal package
+func NewOptional(v interface{}) Optional {
+	return jsonutil.NewOptional(v)
+}
+
+// NullOptional returns the explicit null Optional of the type of example.
+// This function is re-exported from the internal package
+func NullOptional(example interface{}) Optional {
+	return jsonutil.NullOptional(example)
+}
+
+// OmittedOptional returns the omitted Optional of the type of example.
+// This function is re-exported from the internal package
+func OmittedOptional(example interface{}) Optional {
+	return jsonutil.OmittedOptional(example)
+}
+
+var optionalType = reflect.TypeOf(Optional{})
+
+// writeOptionalType writes the nullable GraphQL type of the Optional value v, or nothing if its type is unknown
+func writeOptionalType(w io.Writer, v interface{}) {
+	o, _ := v.(Optional)
+	if o.Type() == nil {
+		return
+	}
+	var buf bytes.Buffer
+	writeArgumentType(&buf, o.Type(), o.Value(), true)
+	io.WriteString(w, strings.TrimSuffix(buf.String(), "!"))
+}
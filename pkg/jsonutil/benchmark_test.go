@@ -2,6 +2,7 @@ package jsonutil_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"reflect"
 	"strings"
@@ -124,3 +125,85 @@ func BenchmarkJSONTokenize(b *testing.B) {
 		}
 	}
 }
+
+type benchmarkListQuery struct {
+	Repositories []struct {
+		ID          string
+		Name        string
+		Description *string
+		Stars       int
+		Score       float64
+		IsPrivate   bool
+		CreatedAt   time.Time
+		Owner       struct {
+			Login string
+		}
+		Topics []string
+	}
+}
+
+// benchmarkListData returns a response of n repositories, like the large lists of real APIs
+func benchmarkListData(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"repositories":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `{
+			"id": "repo-%[1]d",
+			"name": "repository %[1]d",
+			"description": "description of repository %[1]d",
+			"stars": %[1]d,
+			"score": %[1]d.5,
+			"isPrivate": false,
+			"createdAt": "2017-06-29T04:12:01Z",
+			"owner": {"login": "shurcooL-test"},
+			"topics": ["go", "graphql"]
+		}`, i)
+	}
+	sb.WriteString(`]}`)
+	return []byte(sb.String())
+}
+
+func BenchmarkUnmarshalGraphQL_list(b *testing.B) {
+	data := benchmarkListData(1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var got benchmarkListQuery
+		if err := jsonutil.UnmarshalGraphQL(data, &got); err != nil {
+			b.Fatal(err)
+		}
+		if len(got.Repositories) != 1000 || got.Repositories[999].Stars != 999 {
+			b.Fatal("unexpected result")
+		}
+	}
+}
+
+func BenchmarkUnmarshalGraphQL_listStrict(b *testing.B) {
+	data := benchmarkListData(1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var got benchmarkListQuery
+		if err := jsonutil.UnmarshalGraphQL(data, &got, jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONUnmarshal_list(b *testing.B) {
+	data := benchmarkListData(1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var got benchmarkListQuery
+		if err := json.Unmarshal(data, &got); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
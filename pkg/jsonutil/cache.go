@@ -0,0 +1,278 @@
+package jsonutil
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/parser"
+)
+
+var (
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	numberType          = reflect.TypeOf(json.Number(""))
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// structInfoCache caches *structInfo by structInfoKey
+var structInfoCache sync.Map
+
+type structInfoKey struct {
+	t      reflect.Type
+	naming ident.NamingStrategy
+}
+
+// tagCache caches tagEntry by graphql tag values
+var tagCache sync.Map
+
+type tagEntry struct {
+	tag *parser.Tag
+	err error
+}
+
+// typeFlagsCache caches typeFlags by reflect.Type
+var typeFlagsCache sync.Map
+
+type typeFlags uint8
+
+const (
+	// flagPrimitive is set if JSON scalars can be set to values of the type directly,
+	// that is booleans, numbers, strings and empty interfaces without custom unmarshalers
+	flagPrimitive typeFlags = 1 << iota
+	// flagPtrUnmarshaler is set if the pointer to the type implements json.Unmarshaler
+	flagPtrUnmarshaler
+	// flagScalar is set if the type or its pointer element type is a registered scalar
+	flagScalar
+)
+
+// structInfo is the GraphQL layout of a struct type, computed once per type
+// so that the decoder doesn't parse tags for every JSON value
+type structInfo struct {
+	// fields are the exported fields in declaration order
+	fields []fieldInfo
+	// frontier are the fields whose own fields are decoded from the same JSON object,
+	// that is GraphQL fragments and embedded structs
+	frontier []frontierField
+	// embedded are the indexes of untagged embedded structs, whose fields are selected on the same object
+	embedded []int
+	// names maps the likely response keys of fields to their position in fields
+	names map[string]int
+	// err is the error of the first malformed graphql tag, which fails decoding into the struct
+	err error
+}
+
+type fieldInfo struct {
+	index int
+	name  string
+	// graphQLName is the field name or alias of the graphql tag,
+	// or the name given by the naming strategy to untagged fields
+	graphQLName string
+	tagged      bool
+	fragment    bool
+	// scalar fields are tagged as scalar:"true"
+	scalar bool
+	// raw fields are decoded from the complete JSON value: scalars, json.RawMessage, json.Unmarshaler types and registered scalars
+	raw bool
+	// dynamic fields may hold maps or interface{} values, which are decoded by decodeDynamic
+	dynamic bool
+	// selected fields must be in the response in strict mode
+	selected bool
+	// responseKey is the name of the field in error messages
+	responseKey string
+}
+
+type frontierField struct {
+	index     int
+	anonymous bool
+}
+
+// cachedStructInfo returns the GraphQL layout of the struct type t, whose untagged fields are named by naming.
+// The default naming is ident.LowerCamelCase. Layouts aren't cached for strategies that can't be map keys
+func cachedStructInfo(t reflect.Type, naming ident.NamingStrategy) *structInfo {
+	if naming == nil {
+		naming = ident.LowerCamelCase
+	}
+	if !reflect.TypeOf(naming).Comparable() {
+		return newStructInfo(t, naming)
+	}
+	key := structInfoKey{t: t, naming: naming}
+	if info, ok := structInfoCache.Load(key); ok {
+		return info.(*structInfo)
+	}
+	info, _ := structInfoCache.LoadOrStore(key, newStructInfo(t, naming))
+	return info.(*structInfo)
+}
+
+func newStructInfo(t reflect.Type, naming ident.NamingStrategy) *structInfo {
+	info := &structInfo{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		value, tagged := f.Tag.Lookup("graphql")
+		fragment := tagged && keyForGraphQLFragment(value)
+		if fragment || f.Anonymous {
+			info.frontier = append(info.frontier, frontierField{index: i, anonymous: f.Anonymous})
+		}
+		if f.Anonymous && !fragment && !tagged {
+			info.embedded = append(info.embedded, i)
+		}
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		field := fieldInfo{
+			index:       i,
+			name:        f.Name,
+			tagged:      tagged,
+			fragment:    fragment,
+			scalar:      hasScalarTag(f),
+			dynamic:     mayBeDynamic(f.Type),
+			responseKey: f.Name,
+		}
+		if !tagged {
+			field.graphQLName = naming.FieldName(f.Name)
+		}
+		if tagged && value != "-" {
+			if _, err := parseTag(value); err != nil && info.err == nil {
+				info.err = fmt.Errorf("invalid graphql tag %q of struct field `%s`: %w", value, f.Name, err)
+			}
+		}
+		if tagged && !fragment {
+			field.graphQLName, _ = parseGraphQLName(value)
+			field.responseKey = field.graphQLName
+		}
+		field.raw = field.scalar || f.Type == rawMessageType || isUnmarshaler(f.Type) || isRegisteredScalar(f.Type)
+		// Optional fields may be omitted
+		field.selected = !fragment && (tagged || !f.Anonymous) && value != "-" && !isConditional(value) && f.Type != optionalType
+		info.fields = append(info.fields, field)
+	}
+
+	// The map is read only after it's built, so lookups don't need synchronization.
+	info.names = make(map[string]int, len(info.fields))
+	for i, f := range info.fields {
+		candidates := []string{f.graphQLName}
+		if !f.tagged {
+			candidates = append(candidates, f.name, strings.ToLower(f.name[:1])+f.name[1:], strings.ToLower(f.name))
+		}
+		for _, name := range candidates {
+			// a former field with the same name takes precedence
+			if _, ok := info.names[name]; !ok && info.scan(name) == i {
+				info.names[name] = i
+			}
+		}
+	}
+	return info
+}
+
+// parseTag parses the graphql tag value once, or returns the syntax error if it's malformed
+func parseTag(value string) (*parser.Tag, error) {
+	if entry, ok := tagCache.Load(value); ok {
+		return entry.(tagEntry).tag, entry.(tagEntry).err
+	}
+	tag, err := parser.ParseTag(value)
+	tagCache.Store(value, tagEntry{tag: tag, err: err})
+	return tag, err
+}
+
+// isConditional reports whether the field of graphql tag value has the @include or @skip directive,
+// so it may be missing from the response
+func isConditional(value string) bool {
+	tag, err := parseTag(value)
+	return err == nil && (tag.Directive("include") != nil || tag.Directive("skip") != nil)
+}
+
+// field returns the field of struct v that matches GraphQL name,
+// or invalid reflect.Value and nil if none found
+func (s *structInfo) field(v reflect.Value, name string) (reflect.Value, *fieldInfo) {
+	i := s.position(name)
+	if i < 0 {
+		return reflect.Value{}, nil
+	}
+	f := &s.fields[i]
+	return v.Field(f.index), f
+}
+
+// position returns the position in s.fields of the field that matches GraphQL name, or -1 if none found
+func (s *structInfo) position(name string) int {
+	if i, ok := s.names[name]; ok {
+		return i
+	}
+	return s.scan(name)
+}
+
+// scan finds the position of the first field that matches GraphQL name
+func (s *structInfo) scan(name string) int {
+	for i := range s.fields {
+		if s.fields[i].hasGraphQLName(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasGraphQLName reports whether the field has GraphQL name.
+// Untagged fields match the name given by the naming strategy, or their Go name case-insensitively
+func (f *fieldInfo) hasGraphQLName(name string) bool {
+	if !f.tagged {
+		return f.graphQLName == name || strings.EqualFold(f.name, name)
+	}
+	return !f.fragment && f.graphQLName == name
+}
+
+// mayBeDynamic reports whether values of type t may be decoded by decodeDynamic.
+// It's a cheap check of the type, isDynamic must be used to check the value
+func mayBeDynamic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map, reflect.Interface:
+		return true
+	case reflect.Ptr:
+		return mayBeDynamic(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Array && mayBeDynamic(t.Elem())
+	}
+	return false
+}
+
+// isUnmarshaler reports whether the concrete type t decodes itself with json.Unmarshaler
+func isUnmarshaler(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	return t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+// cachedTypeFlags returns the flags of type t, which are computed once per type
+func cachedTypeFlags(t reflect.Type) typeFlags {
+	if flags, ok := typeFlagsCache.Load(t); ok {
+		return flags.(typeFlags)
+	}
+	var flags typeFlags
+	if isRegisteredScalar(t) {
+		// registered scalars are decoded by their config only
+		typeFlagsCache.Store(t, flagScalar)
+		return flagScalar
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if t != numberType && !isUnmarshaler(t) &&
+			!t.Implements(textUnmarshalerType) && !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			flags |= flagPrimitive
+		}
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			flags |= flagPrimitive
+		}
+	}
+	if t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(unmarshalerType) {
+		flags |= flagPtrUnmarshaler
+	}
+	typeFlagsCache.Store(t, flags)
+	return flags
+}
@@ -0,0 +1,222 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hasura/go-graphql-client/ident"
+)
+
+// GenericSelection is implemented by selection templates that only describe the query,
+// e.g. graphql.QueryBuilder. The data of a GenericSelection value in a map or interface{}
+// is decoded into a generic tree of map[string]interface{}
+type GenericSelection interface {
+	GenericSelection()
+}
+
+var genericSelectionType = reflect.TypeOf((*GenericSelection)(nil)).Elem()
+
+// isDynamic reports whether v is decoded from the complete JSON sub-tree
+// instead of the token stream, that is a map with string keys, an empty interface{},
+// a registered union interface or a pointer or slice of them
+func isDynamic(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if isRegisteredUnion(v.Type()) {
+			return true
+		}
+		if v.IsNil() {
+			return v.NumMethod() == 0
+		}
+		return isDynamic(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return isDynamicType(v.Type().Elem())
+		}
+		return isDynamic(v.Elem())
+	case reflect.Slice:
+		if v.Len() > 0 {
+			return !isOrderedMap(v) && isDynamic(v.Index(0))
+		}
+	}
+	return isDynamicType(v.Type())
+}
+
+func isDynamicType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	case reflect.Interface:
+		return t.NumMethod() == 0 || isRegisteredUnion(t)
+	case reflect.Ptr:
+		return isDynamicType(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Array && isDynamicType(t.Elem())
+	}
+	return false
+}
+
+// decodeDynamic decodes the JSON value data into v.
+// Existing values of v are used as templates of the selection set:
+// map values are matched to response keys by their GraphQL names, the first item of a slice is the template of all items.
+// Objects and arrays without template are decoded into a generic tree with numbers as json.Number
+func decodeDynamic(data []byte, v reflect.Value, opts decodeOptions) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			// keep the template
+			elem.Elem().Set(v.Elem())
+		}
+		if err := decodeDynamic(data, elem.Elem(), opts); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Interface:
+		if members, ok := UnionMembers(v.Type()); ok {
+			return decodeUnion(data, v, members, opts)
+		}
+		if v.IsNil() || v.Elem().Type().Implements(genericSelectionType) {
+			return decodeGeneric(data, v)
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := decodeDynamic(data, elem, opts); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		if isOrderedMap(v) {
+			return unmarshalGraphQL(data, v.Addr().Interface(), opts, false)
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		var template reflect.Value
+		if v.Len() > 0 {
+			template = v.Index(0)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if template.IsValid() {
+				slice.Index(i).Set(template)
+			}
+			if err := decodeDynamic(item, slice.Index(i), opts); err != nil {
+				segment := fmt.Sprintf("[%d]", i)
+				return wrapDecodeError(err, segment, segment)
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		return decodeMap(data, v, opts)
+	default:
+		return unmarshalGraphQL(data, v.Addr().Interface(), opts, false)
+	}
+	return nil
+}
+
+// decodeMap decodes the JSON object data into a new map and sets it to v.
+// The map keys of the result are the response keys
+func decodeMap(data []byte, v reflect.Value, opts decodeOptions) error {
+	t := v.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("map keys must be strings, got %v", t.Key())
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	result := reflect.MakeMapWithSize(t, len(fields))
+	for key, raw := range fields {
+		elem := reflect.New(t.Elem()).Elem()
+		if template, ok := mapTemplate(v, key, opts.naming); ok {
+			if !template.Type().AssignableTo(t.Elem()) {
+				return fmt.Errorf("template of %q of type %v is not assignable to %v", key, template.Type(), t.Elem())
+			}
+			elem.Set(template)
+		}
+		if err := decodeDynamic(raw, elem, opts); err != nil {
+			return wrapDecodeError(err, key, fmt.Sprintf("[%q]", key))
+		}
+		result.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+	}
+	v.Set(result)
+	return nil
+}
+
+// mapTemplate returns the value of map m whose key has the GraphQL name.
+// Fields of inline fragments, whose keys start with "...", are searched too
+func mapTemplate(m reflect.Value, name string, naming ident.NamingStrategy) (reflect.Value, bool) {
+	for m.Kind() == reflect.Ptr || m.Kind() == reflect.Interface {
+		m = m.Elem()
+	}
+	if m.Kind() != reflect.Map || m.Len() == 0 {
+		return reflect.Value{}, false
+	}
+	// sort keys to look up fragments in deterministic order
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		if keyHasGraphQLName(key.String(), name) {
+			return m.MapIndex(key), true
+		}
+	}
+	for _, key := range keys {
+		if !keyForGraphQLFragment(key.String()) {
+			continue
+		}
+		fragment := m.MapIndex(key)
+		for fragment.Kind() == reflect.Ptr || fragment.Kind() == reflect.Interface {
+			fragment = fragment.Elem()
+		}
+		switch fragment.Kind() {
+		case reflect.Map:
+			if template, ok := mapTemplate(fragment, name, naming); ok {
+				return template, true
+			}
+		case reflect.Struct:
+			if f, _ := fieldByGraphQLName(fragment, name, naming); f.IsValid() {
+				return f, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// decodeGeneric decodes data into the empty interface v, with numbers as json.Number
+func decodeGeneric(data []byte, v reflect.Value) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	v.Set(reflect.ValueOf(value))
+	return nil
+}
+
+// isCompositeJSON reports whether data is a JSON object or array.
+// Scalars of dynamic struct fields are decoded by encoding/json for backward compatibility
+func isCompositeJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
@@ -0,0 +1,171 @@
+package jsonutil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hasura/go-graphql-client/ident"
+)
+
+// DecodeError is returned by UnmarshalGraphQL when the response data can't be decoded into the query
+type DecodeError struct {
+	// JSONPath is the path of the value in the response data, e.g. user.repositories[2].stars.
+	// It's empty if the error isn't related to a value, e.g. invalid JSON input
+	JSONPath string
+	// FieldPath is the path of the Go field that the value is decoded into, e.g. User.Repositories[2].Stars.
+	// It may be shorter than JSONPath if the value is decoded into a dynamic type, or empty if it can't be resolved
+	FieldPath string
+	Err       error
+}
+
+// Error implements error interface.
+func (e *DecodeError) Error() string {
+	switch {
+	case e.JSONPath == "":
+		return e.Err.Error()
+	case e.FieldPath == "":
+		return fmt.Sprintf("failed to decode %s: %v", e.JSONPath, e.Err)
+	default:
+		return fmt.Sprintf("failed to decode %s into %s: %v", e.JSONPath, e.FieldPath, e.Err)
+	}
+}
+
+// Unwrap returns the underlying error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// pathSegment is an object key or an array index of the JSON path
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// newDecodeError wraps err with the path of the value that the decoder is in the middle of
+func (d *decoder) newDecodeError(err error) error {
+	var sb strings.Builder
+	for _, segment := range d.path {
+		if segment.isIndex {
+			fmt.Fprintf(&sb, "[%d]", segment.index)
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(segment.key)
+	}
+	fieldPath, complete := goFieldPath(d.rootType, d.path, d.options.naming)
+	de := wrapDecodeError(err, sb.String(), fieldPath)
+	if !complete {
+		// the field path of the nested error is relative to an unresolved type
+		de.FieldPath = fieldPath
+	}
+	return de
+}
+
+// wrapDecodeError prepends the paths to err, joining them with the paths of a nested DecodeError
+func wrapDecodeError(err error, jsonPath string, fieldPath string) *DecodeError {
+	var inner *DecodeError
+	if errors.As(err, &inner) {
+		return &DecodeError{
+			JSONPath:  joinPath(jsonPath, inner.JSONPath),
+			FieldPath: joinPath(fieldPath, inner.FieldPath),
+			Err:       inner.Err,
+		}
+	}
+	return &DecodeError{
+		JSONPath:  jsonPath,
+		FieldPath: fieldPath,
+		Err:       err,
+	}
+}
+
+func joinPath(prefix string, path string) string {
+	switch {
+	case prefix == "":
+		return path
+	case path == "":
+		return prefix
+	case strings.HasPrefix(path, "["):
+		return prefix + path
+	default:
+		return prefix + "." + path
+	}
+}
+
+// goFieldPath resolves the JSON path to the path of Go fields, starting from type t.
+// It reports false if the path can't be resolved completely, e.g. values of interface{}
+func goFieldPath(t reflect.Type, path []pathSegment, naming ident.NamingStrategy) (string, bool) {
+	var result string
+	for _, segment := range path {
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil {
+			return result, false
+		}
+		if segment.isIndex {
+			if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+				return result, false
+			}
+			result += fmt.Sprintf("[%d]", segment.index)
+			t = t.Elem()
+			continue
+		}
+		switch {
+		case t.Kind() == reflect.Struct:
+			name, ft, ok := structFieldPath(t, segment.key, naming)
+			if !ok {
+				return result, false
+			}
+			result = joinPath(result, name)
+			t = ft
+		case t.Kind() == reflect.Map, t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Array:
+			result += fmt.Sprintf("[%q]", segment.key)
+			t = t.Elem()
+			if t.Kind() == reflect.Array {
+				// the value of ordered map pairs is interface{}
+				t = t.Elem()
+			}
+		default:
+			return result, false
+		}
+	}
+	return result, true
+}
+
+// structFieldPath finds the field of struct type t that matches GraphQL name, in embedded structs and fragments too.
+// It returns the Go path of the field relative to t and its type
+func structFieldPath(t reflect.Type, name string, naming ident.NamingStrategy) (string, reflect.Type, bool) {
+	info := cachedStructInfo(t, naming)
+	if i := info.position(name); i >= 0 {
+		f := t.Field(info.fields[i].index)
+		return f.Name, f.Type, true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous && !isGraphQLFragment(f) {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		path, fieldType, ok := structFieldPath(ft, name, naming)
+		if !ok {
+			continue
+		}
+		if _, tagged := f.Tag.Lookup("graphql"); f.Anonymous && !tagged {
+			// fields of embedded structs are promoted
+			return path, fieldType, true
+		}
+		return f.Name + "." + path, fieldType, true
+	}
+	return "", nil, false
+}
@@ -10,7 +10,9 @@ import (
 	"io"
 	"reflect"
 	"strconv"
-	"strings"
+
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/parser"
 )
 
 // UnmarshalGraphQL parses the JSON-encoded GraphQL response data and stores
@@ -18,10 +20,22 @@ import (
 //
 // The implementation is created on top of the JSON tokenizer available
 // in "encoding/json".Decoder.
-func UnmarshalGraphQL(data []byte, v interface{}) error {
+//
+// The behavior on differences between the response and v is controlled by WithDecodeMode.
+func UnmarshalGraphQL(data []byte, v interface{}, options ...DecoderOption) error {
+	var opts decodeOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return unmarshalGraphQL(data, v, opts, false)
+}
+
+// unmarshalGraphQL decodes data into v.
+// If ignoreTypename is true, __typename is skipped when v doesn't select it, e.g. members of unions
+func unmarshalGraphQL(data []byte, v interface{}, opts decodeOptions, ignoreTypename bool) error {
 	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.UseNumber()
-	err := (&decoder{tokenizer: dec}).Decode(v)
+	err := (&decoder{tokenizer: dec, options: opts, ignoreTypename: ignoreTypename}).Decode(v)
 	if err != nil {
 		return err
 	}
@@ -56,6 +70,24 @@ type decoder struct {
 	// a single JSON value into multiple GraphQL fragments or embedded structs, so
 	// we keep track of them all.
 	vs []stack
+
+	// Stack of objects we're in the middle of, to check selected fields in strict mode.
+	objects []objectState
+
+	// Path of the current value from the root, and the next item index of each array we're in the middle of.
+	path     []pathSegment
+	indexes  []int
+	rootType reflect.Type
+
+	options decodeOptions
+	// ignoreTypename skips __typename of the top-level object if no field selects it
+	ignoreTypename bool
+}
+
+// objectState holds the structs that a JSON object is decoded into, and the keys seen so far
+type objectState struct {
+	structs []reflect.Value
+	seen    map[string]bool
 }
 
 type stack []reflect.Value
@@ -74,14 +106,26 @@ func (d *decoder) Decode(v interface{}) error {
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("cannot decode into non-pointer %T", v)
 	}
+	d.rootType = rv.Type().Elem()
+	if isDynamic(rv.Elem()) {
+		var data json.RawMessage
+		if err := d.tokenizer.Decode(&data); err != nil {
+			return err
+		}
+		if !isCompositeJSON(data) {
+			return unmarshalValue(data, rv.Elem())
+		}
+		return decodeDynamic(data, rv.Elem(), d.options)
+	}
 	d.vs = []stack{{rv.Elem()}}
-	return d.decode()
+	if err := d.decode(); err != nil {
+		return d.newDecodeError(err)
+	}
+	return nil
 }
 
 // decode decodes a single JSON value from d.tokenizer into d.vs.
 func (d *decoder) decode() error {
-	rawMessageValue := reflect.ValueOf(json.RawMessage{})
-
 	// The loop invariant is that the top of each d.vs stack
 	// is where we try to unmarshal the next JSON value we see.
 	for len(d.vs) > 0 {
@@ -102,10 +146,14 @@ func (d *decoder) decode() error {
 			if !ok {
 				return errors.New("unexpected non-key in JSON input")
 			}
+			d.path = append(d.path, pathSegment{key: key})
+			if d.options.mode == DecodeModeStrict {
+				d.objects[len(d.objects)-1].seen[key] = true
+			}
 			someFieldExist := false
 			// If one field is raw all must be treated as raw
-			rawMessage := false
-			isScalar := false
+			raw := false
+			dynamic := false
 			for i := range d.vs {
 				v := d.vs[i].Top()
 				for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
@@ -114,27 +162,69 @@ func (d *decoder) decode() error {
 				var f reflect.Value
 				switch v.Kind() {
 				case reflect.Struct:
-					f, isScalar = fieldByGraphQLName(v, key)
+					structInfo := cachedStructInfo(v.Type(), d.options.naming)
+					if structInfo.err != nil {
+						return structInfo.err
+					}
+					var info *fieldInfo
+					f, info = structInfo.field(v, key)
 					if f.IsValid() {
 						someFieldExist = true
-						// Check for special embedded json
-						if f.Type() == rawMessageValue.Type() {
-							rawMessage = true
-						}
+						// Scalars, embedded json and types with their own unmarshaler are read raw
+						raw = raw || info.raw
+						dynamic = dynamic || (info.dynamic && isDynamic(f))
 					}
 				case reflect.Slice:
-					f = orderedMapValueByGraphQLName(v, key)
+					f, err = orderedMapValueByGraphQLName(v, key)
+					if err != nil {
+						return err
+					}
 					if f.IsValid() {
 						someFieldExist = true
+						dynamic = dynamic || isDynamic(f)
 					}
 				}
 				d.vs[i] = append(d.vs[i], f)
 			}
 			if !someFieldExist {
+				if d.options.mode == DecodeModeLenient || (d.ignoreTypename && key == "__typename" && len(d.parseState) == 1) {
+					// Skip the value of the unknown key.
+					var data json.RawMessage
+					if err := d.tokenizer.Decode(&data); err != nil {
+						return err
+					}
+					d.popAllVs()
+					continue
+				}
 				return fmt.Errorf("struct field for %q doesn't exist in any of %v places to unmarshal", key, len(d.vs))
 			}
 
-			if rawMessage || isScalar {
+			if dynamic && !raw {
+				// Maps and interface{} values are decoded from the complete sub-tree
+				var data json.RawMessage
+				err = d.tokenizer.Decode(&data)
+				if err != nil {
+					return err
+				}
+				for i := range d.vs {
+					v := d.vs[i].Top()
+					if !v.IsValid() {
+						continue
+					}
+					if isDynamic(v) && isCompositeJSON(data) {
+						err = decodeDynamic(data, v, d.options)
+					} else {
+						err = unmarshalValue(data, v)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				d.popAllVs()
+				continue
+			}
+
+			if raw {
 				// Read the next complete object from the json stream
 				var data json.RawMessage
 				err = d.tokenizer.Decode(&data)
@@ -155,6 +245,9 @@ func (d *decoder) decode() error {
 
 		// Are we inside an array and seeing next value (rather than end of array)?
 		case d.state() == '[' && tok != json.Delim(']'):
+			index := d.indexes[len(d.indexes)-1]
+			d.indexes[len(d.indexes)-1]++
+			d.path = append(d.path, pathSegment{index: index, isIndex: true})
 			someSliceExist := false
 			for i := range d.vs {
 				v := d.vs[i].Top()
@@ -189,6 +282,9 @@ func (d *decoder) decode() error {
 				if !v.IsValid() {
 					continue
 				}
+				if d.options.mode == DecodeModeStrict && isNullToken(tok) && !isNullable(v) {
+					return fmt.Errorf("null value for non-pointer type %v", v.Type())
+				}
 				err := unmarshalValue(tok, v)
 				if err != nil {
 					return err
@@ -212,6 +308,13 @@ func (d *decoder) decode() error {
 						v.Set(reflect.New(v.Type().Elem())) // v = new(T).
 					}
 				}
+				if d.options.mode == DecodeModeStrict {
+					state := objectState{seen: map[string]bool{}}
+					for i := range d.vs {
+						state.structs = append(state.structs, selectedStructs(d.vs[i].Top(), d.options.naming)...)
+					}
+					d.objects = append(d.objects, state)
+				}
 				// Find GraphQL fragments/embedded structs recursively, adding to frontier
 				// as new ones are discovered and exploring them further.
 				for len(frontier) > 0 {
@@ -221,12 +324,15 @@ func (d *decoder) decode() error {
 						v = v.Elem()
 					}
 					if v.Kind() == reflect.Struct {
-						for i := 0; i < v.NumField(); i++ {
-							if isGraphQLFragment(v.Type().Field(i)) || v.Type().Field(i).Anonymous {
-								// Add GraphQL fragment or embedded struct.
-								d.vs = append(d.vs, []reflect.Value{v.Field(i)})
-								frontier = append(frontier, v.Field(i))
+						for _, field := range cachedStructInfo(v.Type(), d.options.naming).frontier {
+							f := v.Field(field.index)
+							// Allocate embedded struct pointers, e.g. spreads of named fragments.
+							if field.anonymous && f.Kind() == reflect.Ptr && f.IsNil() && f.CanSet() {
+								f.Set(reflect.New(f.Type().Elem())) // f = new(T).
 							}
+							// Add GraphQL fragment or embedded struct.
+							d.vs = append(d.vs, []reflect.Value{f})
+							frontier = append(frontier, f)
 						}
 					} else if isOrderedMap(v) {
 						for i := 0; i < v.Len(); i++ {
@@ -244,6 +350,7 @@ func (d *decoder) decode() error {
 				// Start of array.
 
 				d.pushState(tok)
+				d.indexes = append(d.indexes, 0)
 
 				for i := range d.vs {
 					v := d.vs[i].Top()
@@ -275,6 +382,13 @@ func (d *decoder) decode() error {
 				}
 			case '}':
 				// End of object.
+				if d.options.mode == DecodeModeStrict {
+					state := d.objects[len(d.objects)-1]
+					d.objects = d.objects[:len(d.objects)-1]
+					if err := checkSelectedFields(state, d.options.naming); err != nil {
+						return err
+					}
+				}
 				d.popAllVs()
 				d.popState()
 			case ']':
@@ -282,6 +396,7 @@ func (d *decoder) decode() error {
 				d.popLeftArrayTemplates()
 				d.popAllVs()
 				d.popState()
+				d.indexes = d.indexes[:len(d.indexes)-1]
 			default:
 				return errors.New("unexpected delimiter in JSON input")
 			}
@@ -343,8 +458,13 @@ func (d *decoder) state() json.Delim {
 }
 
 // popAllVs pops from all d.vs stacks, keeping only non-empty ones.
+// The path segment of the decoded value is popped too.
 func (d *decoder) popAllVs() {
-	var nonEmpty []stack
+	if len(d.path) > 0 {
+		d.path = d.path[:len(d.path)-1]
+	}
+	// Filter in place, popping is done for every JSON value.
+	nonEmpty := d.vs[:0]
 	for i := range d.vs {
 		d.vs[i] = d.vs[i].Pop()
 		if len(d.vs[i]) > 0 {
@@ -366,30 +486,30 @@ func (d *decoder) popLeftArrayTemplates() {
 
 // fieldByGraphQLName returns an exported struct field of struct v
 // that matches GraphQL name, or invalid reflect.Value if none found.
-func fieldByGraphQLName(v reflect.Value, name string) (val reflect.Value, taggedAsScalar bool) {
-	for i := 0; i < v.NumField(); i++ {
-		if v.Type().Field(i).PkgPath != "" {
-			// Skip unexported field.
-			continue
-		}
-		if hasGraphQLName(v.Type().Field(i), name) {
-			return v.Field(i), hasScalarTag(v.Type().Field(i))
-		}
+// The fields of each struct type are parsed once and cached.
+func fieldByGraphQLName(v reflect.Value, name string, naming ident.NamingStrategy) (val reflect.Value, taggedAsScalar bool) {
+	f, info := cachedStructInfo(v.Type(), naming).field(v, name)
+	if info == nil {
+		return reflect.Value{}, false
 	}
-	return reflect.Value{}, false
+	return f, info.scalar
 }
 
 // orderedMapValueByGraphQLName takes [][2]string, interprets it as an ordered map
 // and returns value for corresponding key, or invalid reflect.Value if none found.
-func orderedMapValueByGraphQLName(v reflect.Value, name string) reflect.Value {
+// Malformed keys fail the lookup, because the data of their fields would be dropped.
+func orderedMapValueByGraphQLName(v reflect.Value, name string) (reflect.Value, error) {
 	for i := 0; i < v.Len(); i++ {
 		pair := v.Index(i)
 		key := pair.Index(0).Interface().(string)
+		if _, err := parseTag(key); err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid key %q of ordered map: %w", key, err)
+		}
 		if keyHasGraphQLName(key, name) {
-			return pair.Index(1)
+			return pair.Index(1), nil
 		}
 	}
-	return reflect.Value{}
+	return reflect.Value{}, nil
 }
 
 func hasScalarTag(f reflect.StructField) bool {
@@ -401,30 +521,21 @@ func isTrue(s string) bool {
 	return b
 }
 
-// hasGraphQLName reports whether struct field f has GraphQL name.
-func hasGraphQLName(f reflect.StructField, name string) bool {
-	value, ok := f.Tag.Lookup("graphql")
-	if !ok {
-		// TODO: caseconv package is relatively slow. Optimize it, then consider using it here.
-		//return caseconv.MixedCapsToLowerCamelCase(f.Name) == name
-		return strings.EqualFold(f.Name, name)
-	}
-	return keyHasGraphQLName(value, name)
+func keyHasGraphQLName(value, name string) bool {
+	key, ok := parseGraphQLName(value)
+	return ok && key == name
 }
 
-func keyHasGraphQLName(value, name string) bool {
-	value = strings.TrimSpace(value) // TODO: Parse better.
-	if strings.HasPrefix(value, "...") {
-		// GraphQL fragment. It doesn't have a name.
-		return false
+// parseGraphQLName returns the field name or alias of graphql tag value,
+// or false if the value is a GraphQL fragment, which doesn't have a name, or it's malformed.
+// The tag is parsed by the same parser as the query writer, so both agree on the response key.
+// Decoding into structs with malformed tags fails, see structInfo
+func parseGraphQLName(value string) (string, bool) {
+	tag, err := parseTag(value)
+	if err != nil || tag.Kind != parser.FieldTag {
+		return "", false
 	}
-	if i := strings.Index(value, "("); i != -1 {
-		value = value[:i]
-	}
-	if i := strings.Index(value, ":"); i != -1 {
-		value = value[:i]
-	}
-	return strings.TrimSpace(value) == name
+	return tag.ResponseKey(), true
 }
 
 // isGraphQLFragment reports whether struct field f is a GraphQL fragment.
@@ -438,30 +549,141 @@ func isGraphQLFragment(f reflect.StructField) bool {
 
 // isGraphQLFragment reports whether ordered map kv pair f is a GraphQL fragment.
 func keyForGraphQLFragment(value string) bool {
-	value = strings.TrimSpace(value) // TODO: Parse better.
-	return strings.HasPrefix(value, "...")
+	tag, err := parseTag(value)
+	return err == nil && tag.Kind != parser.FieldTag
 }
 
 // unmarshalValue unmarshals JSON value into v.
 // v must be addressable and not obtained by the use of unexported
 // struct fields, otherwise unmarshalValue will panic.
+//
+// Scalar tokens are set to primitive types directly, raw JSON values are
+// unmarshaled without encoding them again. Other values fall back to encoding/json.
 func unmarshalValue(value interface{}, v reflect.Value) error {
-	b, err := json.Marshal(value) // TODO: Short-circuit (if profiling says it's worth it).
-	if err != nil {
+	if ok, err := setPrimitive(value, v); ok {
 		return err
 	}
 	ty := v.Type()
+	raw, isRaw := value.(json.RawMessage)
+	if !isRaw || ty == rawMessageType {
+		// Embedded json is compacted by encoding it again.
+		var err error
+		raw, err = json.Marshal(value)
+		if err != nil {
+			return err
+		}
+	}
+	if cachedTypeFlags(ty)&flagScalar != 0 {
+		return unmarshalScalar(raw, v)
+	}
 	if ty.Kind() == reflect.Interface {
 		if !v.Elem().IsValid() {
-			return json.Unmarshal(b, v.Addr().Interface())
+			return json.Unmarshal(raw, v.Addr().Interface())
 		}
 		ty = v.Elem().Type()
 	}
 	newVal := reflect.New(ty)
-	err = json.Unmarshal(b, newVal.Interface())
-	if err != nil {
+	if ty == optionalType && v.Type() == optionalType {
+		// the value is decoded into the type of the Optional
+		newVal.Elem().Set(v)
+	}
+	if isRaw && ty.Kind() != reflect.Ptr && cachedTypeFlags(ty)&flagPtrUnmarshaler != 0 {
+		// The raw message is a valid JSON value read by the tokenizer, it doesn't need to be checked again.
+		if err := newVal.Interface().(json.Unmarshaler).UnmarshalJSON(raw); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(raw, newVal.Interface()); err != nil {
 		return err
 	}
 	v.Set(newVal.Elem())
 	return nil
 }
+
+// setPrimitive sets the JSON token value to v if v is a primitive type, see isPrimitiveType.
+// It reports false if the value must be decoded by encoding/json, e.g. to get its error for mismatched types.
+func setPrimitive(value interface{}, v reflect.Value) (bool, error) {
+	ty := v.Type()
+	switch value := value.(type) {
+	case json.Number:
+		if ty == numberType {
+			v.SetString(string(value))
+			return true, nil
+		}
+	}
+	if cachedTypeFlags(ty)&flagPrimitive == 0 {
+		return false, nil
+	}
+	if ty.Kind() == reflect.Interface {
+		if !v.IsNil() {
+			// the value is decoded into the type of the existing value
+			return false, nil
+		}
+		switch value := value.(type) {
+		case nil:
+			v.Set(reflect.Zero(ty))
+		case string, bool:
+			v.Set(reflect.ValueOf(value))
+		case json.Number:
+			f, err := strconv.ParseFloat(string(value), 64)
+			if err != nil {
+				return false, nil
+			}
+			v.Set(reflect.ValueOf(f))
+		default:
+			return false, nil
+		}
+		return true, nil
+	}
+
+	switch value := value.(type) {
+	case nil:
+		v.Set(reflect.Zero(ty))
+	case string:
+		if ty.Kind() != reflect.String {
+			return false, nil
+		}
+		v.SetString(value)
+	case bool:
+		if ty.Kind() != reflect.Bool {
+			return false, nil
+		}
+		v.SetBool(value)
+	case json.Number:
+		switch ty.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(string(value), 10, ty.Bits())
+			if err != nil {
+				return false, nil
+			}
+			v.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n, err := strconv.ParseUint(string(value), 10, ty.Bits())
+			if err != nil {
+				return false, nil
+			}
+			v.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(string(value), ty.Bits())
+			if err != nil {
+				return false, nil
+			}
+			v.SetFloat(f)
+		default:
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// isNullToken reports whether the JSON token is null, or raw JSON null
+func isNullToken(tok interface{}) bool {
+	switch tok := tok.(type) {
+	case nil:
+		return true
+	case json.RawMessage:
+		return bytes.Equal(bytes.TrimSpace(tok), []byte("null"))
+	}
+	return false
+}
@@ -2,10 +2,16 @@ package jsonutil_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hasura/go-graphql-client/ident"
 	"github.com/hasura/go-graphql-client/pkg/jsonutil"
 )
 
@@ -419,7 +425,7 @@ func TestUnmarshalGraphQL_unexportedField(t *testing.T) {
 	if err == nil {
 		t.Fatal("got error: nil, want: non-nil")
 	}
-	if got, want := err.Error(), "struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
+	if got, want := err.Error(), "failed to decode foo: struct field for \"foo\" doesn't exist in any of 1 places to unmarshal"; got != want {
 		t.Errorf("got error: %v, want: %v", got, want)
 	}
 }
@@ -625,3 +631,675 @@ func TestUnmarshalGraphQL_arrayInsideInlineFragment(t *testing.T) {
 		t.Error("not equal")
 	}
 }
+
+type UserFields struct {
+	ID   string
+	Name string
+}
+
+func TestUnmarshalGraphQL_namedFragmentSpread(t *testing.T) {
+	type query struct {
+		Viewer struct {
+			*UserFields
+			Email string
+		}
+		User struct {
+			UserFields
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"viewer": {
+			"id": "1",
+			"name": "Gopher",
+			"email": "gopher@example.com"
+		},
+		"user": {
+			"id": "2",
+			"name": "Hasura"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Viewer.UserFields = &UserFields{ID: "1", Name: "Gopher"}
+	want.Viewer.Email = "gopher@example.com"
+	want.User.UserFields = UserFields{ID: "2", Name: "Hasura"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_map(t *testing.T) {
+	type repository struct {
+		Name  string
+		Stars int
+	}
+	got := map[string]interface{}{
+		"viewer":                        map[string]interface{}{"login": nil, "... on User": map[string]interface{}{"email": nil}},
+		"repository(name: $name)":       repository{},
+		"topics: repositoryTopics":      []repository{},
+		"meta":                          nil,
+		"issues(first: 2)":              []interface{}{},
+		"unknown(arg: \"not in data\")": nil,
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"viewer": {"login": "gopher", "email": "gopher@example.com"},
+		"repository": {"name": "go-graphql-client", "stars": 300},
+		"topics": [{"name": "go", "stars": 1}],
+		"meta": {"count": 42, "ratio": 0.5, "tags": ["a", null]},
+		"issues": [{"number": 1}, {"number": 2}]
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"viewer":     map[string]interface{}{"login": "gopher", "email": "gopher@example.com"},
+		"repository": repository{Name: "go-graphql-client", Stars: 300},
+		"topics":     []repository{{Name: "go", Stars: 1}},
+		"meta": map[string]interface{}{
+			"count": json.Number("42"),
+			"ratio": json.Number("0.5"),
+			"tags":  []interface{}{"a", nil},
+		},
+		"issues": []interface{}{
+			map[string]interface{}{"number": json.Number("1")},
+			map[string]interface{}{"number": json.Number("2")},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_mapField(t *testing.T) {
+	type query struct {
+		User    map[string]interface{} `graphql:"user(id: $id)"`
+		Labels  []map[string]string
+		Payload interface{}
+		Score   interface{}
+		Config  map[string]interface{} `scalar:"true"`
+	}
+	got := query{
+		User: map[string]interface{}{"name": nil},
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"user": {"name": "Gopher", "age": 7},
+		"labels": [{"name": "bug"}, {"name": "feature"}],
+		"payload": {"items": [1, 2]},
+		"score": 4.5,
+		"config": {"retries": 3}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		User:    map[string]interface{}{"name": "Gopher", "age": json.Number("7")},
+		Labels:  []map[string]string{{"name": "bug"}, {"name": "feature"}},
+		Payload: map[string]interface{}{"items": []interface{}{json.Number("1"), json.Number("2")}},
+		// scalars keep the types of encoding/json
+		Score:  4.5,
+		Config: map[string]interface{}{"retries": float64(3)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	var null query
+	null.User = map[string]interface{}{"name": nil}
+	if err := jsonutil.UnmarshalGraphQL([]byte(`{"user": null, "labels": null}`), &null); err != nil {
+		t.Fatal(err)
+	}
+	if null.User != nil || null.Labels != nil {
+		t.Errorf("got %+v, want nil user and labels", null)
+	}
+}
+
+type searchResult interface {
+	isSearchResult()
+}
+
+type searchUser struct {
+	Login string
+}
+
+func (searchUser) isSearchResult() {}
+
+type searchRepository struct {
+	Typename string `graphql:"__typename"`
+	Name     string
+}
+
+func (*searchRepository) isSearchResult() {}
+
+func TestUnmarshalGraphQL_registeredUnion(t *testing.T) {
+	err := jsonutil.RegisterUnion((*searchResult)(nil), map[string]interface{}{
+		"User":       searchUser{},
+		"Repository": &searchRepository{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Search []searchResult
+		First  searchResult
+		Empty  searchResult
+		Latest searchResult
+	}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{
+		"search": [
+			{"__typename": "User", "login": "gopher"},
+			{"__typename": "Repository", "name": "go-graphql-client"},
+			{"__typename": "Organization"}
+		],
+		"first": {"__typename": "User", "login": "hasura"},
+		"empty": null
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []searchResult{
+		searchUser{Login: "gopher"},
+		&searchRepository{Typename: "Repository", Name: "go-graphql-client"},
+		// unknown types are decoded as nil
+		nil,
+	}
+	if !reflect.DeepEqual(got.Search, want) {
+		t.Errorf("not equal\ngot:  %#v\nwant: %#v", got.Search, want)
+	}
+	if got.First != (searchUser{Login: "hasura"}) {
+		t.Errorf("got %#v, want hasura", got.First)
+	}
+	if got.Empty != nil || got.Latest != nil {
+		t.Errorf("got %#v and %#v, want nil", got.Empty, got.Latest)
+	}
+
+	var missingTypename struct {
+		First searchResult
+	}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"first": {"login": "gopher"}}`), &missingTypename)
+	if err == nil || err.Error() != "failed to decode first into First: __typename is required to decode the union jsonutil_test.searchResult" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegisterUnion_errors(t *testing.T) {
+	fixtures := []struct {
+		iface interface{}
+		types map[string]interface{}
+		want  string
+	}{
+		{searchUser{}, nil, "expected a pointer to an interface, got jsonutil_test.searchUser"},
+		{(*interface{})(nil), nil, "the union interface must have methods"},
+		{(*searchResult)(nil), nil, "no member type of jsonutil_test.searchResult"},
+		{(*searchResult)(nil), map[string]interface{}{"Repository": searchRepository{}}, "jsonutil_test.searchRepository of Repository doesn't implement jsonutil_test.searchResult"},
+	}
+	for _, f := range fixtures {
+		err := jsonutil.RegisterUnion(f.iface, f.types)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("got error: %v, want: %s", err, f.want)
+		}
+	}
+}
+
+func TestUnmarshalGraphQL_lenientMode(t *testing.T) {
+	type query struct {
+		User struct {
+			Name string
+		}
+		Tags [][2]interface{}
+	}
+	data := []byte(`{
+		"user": {"name": "Gopher", "email": "gopher@example.com", "repositories": [{"name": "go"}]},
+		"tags": {"name": "go", "color": "blue"},
+		"extra": {"count": 1}
+	}`)
+
+	var got query
+	err := jsonutil.UnmarshalGraphQL(data, &got)
+	if err == nil || err.Error() != `failed to decode user.email into User: struct field for "email" doesn't exist in any of 1 places to unmarshal` {
+		t.Errorf("unexpected error in default mode: %v", err)
+	}
+
+	got = query{Tags: [][2]interface{}{{"name", ""}}}
+	if err := jsonutil.UnmarshalGraphQL(data, &got, jsonutil.WithDecodeMode(jsonutil.DecodeModeLenient)); err != nil {
+		t.Fatal(err)
+	}
+	if got.User.Name != "Gopher" || !reflect.DeepEqual(got.Tags, [][2]interface{}{{"name", "go"}}) {
+		t.Errorf("unexpected result: %+v", got)
+	}
+}
+
+func TestUnmarshalGraphQL_strictMode(t *testing.T) {
+	type userFields struct {
+		Login string
+	}
+	type query struct {
+		User struct {
+			userFields
+			Name    string
+			Email   *string
+			Avatar  string                `graphql:"avatar @include(if: $withAvatar)"`
+			Admin   struct{ Role string } `graphql:"... on Admin"`
+			Ignored string                `graphql:"-"`
+		} `graphql:"me: user(id: $id)"`
+		Count int
+	}
+	strict := jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict)
+
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{"me": {"login": "gopher", "name": "Gopher", "email": null}, "count": 1}`), &got, strict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.User.Login != "gopher" || got.User.Email != nil || got.Count != 1 {
+		t.Errorf("unexpected result: %+v", got)
+	}
+
+	fixtures := []struct {
+		data string
+		want string
+	}{
+		{
+			data: `{"me": {"login": "gopher", "email": null}, "count": 1}`,
+			want: `failed to decode me into User: selected field "Name" of struct { jsonutil_test.userFields; Name string; Email *string; Avatar string "graphql:\"avatar @include(if: $withAvatar)\""; Admin struct { Role string } "graphql:\"... on Admin\""; Ignored string "graphql:\"-\"" } is missing from the response`,
+		},
+		{
+			data: `{"me": {"name": "Gopher", "email": null}, "count": 1}`,
+			want: `failed to decode me into User: selected field "Login" of jsonutil_test.userFields is missing from the response`,
+		},
+		{
+			data: `{"count": 1}`,
+			want: `selected field "me" of jsonutil_test.query is missing from the response`,
+		},
+		{
+			data: `{"me": {"login": "gopher", "name": "Gopher", "email": null}, "count": null}`,
+			want: `failed to decode count into Count: null value for non-pointer type int`,
+		},
+		{
+			data: `{"me": {"login": "gopher", "name": "Gopher", "email": null, "age": 1}, "count": 1}`,
+			want: `failed to decode me.age into User: struct field for "age" doesn't exist in any of 3 places to unmarshal`,
+		},
+	}
+	for _, f := range fixtures {
+		var q query
+		err := jsonutil.UnmarshalGraphQL([]byte(f.data), &q, strict)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("%s:\ngot error: %v\nwant:      %s", f.data, err, f.want)
+		}
+	}
+}
+
+func TestUnmarshalGraphQL_invalidTag(t *testing.T) {
+	var q struct {
+		User struct {
+			Name string `graphql:"name("`
+		}
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{"user": {"name": "Gopher"}}`), &q)
+	if want := `failed to decode user.name into User: invalid graphql tag "name(" of struct field ` + "`Name`" + `: graphql syntax error (1:6): expected name, got <EOF>`; err == nil || err.Error() != want {
+		t.Errorf("got error: %v\nwant:      %s", err, want)
+	}
+
+	var m struct {
+		User [][2]interface{}
+	}
+	m.User = [][2]interface{}{{"name(", new(string)}}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"user": {"name": "Gopher"}}`), &m)
+	if want := `failed to decode user.name into User["name"]: invalid key "name(" of ordered map: graphql syntax error (1:6): expected name, got <EOF>`; err == nil || err.Error() != want {
+		t.Errorf("got error: %v\nwant:      %s", err, want)
+	}
+}
+
+func TestUnmarshalGraphQL_decodeErrorPath(t *testing.T) {
+	type repository struct {
+		Name  string
+		Stars int
+	}
+	type droid struct {
+		PrimaryFunction int
+	}
+	type query struct {
+		User struct {
+			Repositories []repository `graphql:"repos: repositories(first: 10)"`
+			Droid        droid        `graphql:"... on Droid"`
+			Meta         map[string]interface{}
+		}
+		Search []searchResult
+	}
+	if err := jsonutil.RegisterUnion((*searchResult)(nil), map[string]interface{}{
+		"User":       searchUser{},
+		"Repository": &searchRepository{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures := []struct {
+		setup     func(q *query)
+		data      string
+		jsonPath  string
+		fieldPath string
+		want      string
+	}{
+		{
+			data:      `{"user": {"repos": [{"name": "a", "stars": 1}, {"name": "b", "stars": "many"}]}}`,
+			jsonPath:  "user.repos[1].stars",
+			fieldPath: "User.Repositories[1].Stars",
+			want:      "failed to decode user.repos[1].stars into User.Repositories[1].Stars: json: cannot unmarshal string into Go value of type int",
+		},
+		{
+			data:      `{"user": {"primaryFunction": "astromech"}}`,
+			jsonPath:  "user.primaryFunction",
+			fieldPath: "User.Droid.PrimaryFunction",
+		},
+		{
+			setup: func(q *query) {
+				q.User.Meta = map[string]interface{}{"repository": repository{}}
+			},
+			data:      `{"user": {"meta": {"repository": {"name": 1}}}}`,
+			jsonPath:  "user.meta.repository.name",
+			fieldPath: `User.Meta["repository"].Name`,
+		},
+		{
+			data:      `{"search": [{"__typename": "User", "login": "a"}, {"__typename": "Repository", "name": false}]}`,
+			jsonPath:  "search[1].name",
+			fieldPath: "Search[1].(*jsonutil_test.searchRepository).Name",
+		},
+	}
+	for _, f := range fixtures {
+		var q query
+		if f.setup != nil {
+			f.setup(&q)
+		}
+		err := jsonutil.UnmarshalGraphQL([]byte(f.data), &q)
+		var decodeErr *jsonutil.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: expected DecodeError, got %v", f.data, err)
+			continue
+		}
+		if decodeErr.JSONPath != f.jsonPath || decodeErr.FieldPath != f.fieldPath {
+			t.Errorf("%s: got paths %s and %s, want %s and %s", f.data, decodeErr.JSONPath, decodeErr.FieldPath, f.jsonPath, f.fieldPath)
+		}
+		if f.want != "" && err.Error() != f.want {
+			t.Errorf("got error: %s, want: %s", err, f.want)
+		}
+	}
+}
+
+type upperString string
+
+func (s *upperString) UnmarshalText(text []byte) error {
+	*s = upperString(strings.ToUpper(string(text)))
+	return nil
+}
+
+// point is a custom scalar that decodes itself from a JSON array or object
+type point struct {
+	X, Y int
+}
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	var xy [2]int
+	if err := json.Unmarshal(data, &xy); err == nil {
+		p.X, p.Y = xy[0], xy[1]
+		return nil
+	}
+	var obj struct{ Lat, Lng int }
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	p.X, p.Y = obj.Lat, obj.Lng
+	return nil
+}
+
+func TestUnmarshalGraphQL_scalarTypes(t *testing.T) {
+	type status string
+	type query struct {
+		Status   status
+		Small    int8
+		Count    uint
+		Ratio    float32
+		Number   json.Number
+		Any      interface{}
+		AnyText  interface{}
+		Upper    upperString
+		Location point
+		Origin   *point
+		Nothing  *string
+		Missing  int
+	}
+	var got query
+	got.Missing = 1
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"status": "OPEN",
+		"small": -8,
+		"count": 42,
+		"ratio": 0.5,
+		"number": 1e3,
+		"any": 1.5,
+		"anyText": "text",
+		"upper": "loud",
+		"location": {"lat": 1, "lng": 2},
+		"origin": [3, 4],
+		"nothing": null,
+		"missing": null
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Status:   "OPEN",
+		Small:    -8,
+		Count:    42,
+		Ratio:    0.5,
+		Number:   "1e3",
+		Any:      1.5,
+		AnyText:  "text",
+		Upper:    "LOUD",
+		Location: point{X: 1, Y: 2},
+		Origin:   &point{X: 3, Y: 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	// errors of mismatched types are the same as encoding/json
+	fixtures := []struct {
+		data string
+		want string
+	}{
+		{
+			data: `{"small": 128}`,
+			want: "failed to decode small into Small: json: cannot unmarshal number 128 into Go value of type int8",
+		},
+		{
+			data: `{"count": -1}`,
+			want: "failed to decode count into Count: json: cannot unmarshal number -1 into Go value of type uint",
+		},
+		{
+			data: `{"status": 1}`,
+			want: "failed to decode status into Status: json: cannot unmarshal number into Go value of type jsonutil_test.status",
+		},
+	}
+	for _, f := range fixtures {
+		var q query
+		err := jsonutil.UnmarshalGraphQL([]byte(f.data), &q)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("%s:\ngot error: %v\nwant:      %s", f.data, err, f.want)
+		}
+	}
+
+	// null values of custom scalars are still checked in strict mode
+	var strict struct {
+		CreatedAt time.Time
+	}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"createdAt": null}`), &strict, jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict))
+	if want := "failed to decode createdAt into CreatedAt: null value for non-pointer type time.Time"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
+
+func TestUnmarshalGraphQL_tagSyntax(t *testing.T) {
+	type query struct {
+		User struct {
+			Avatar string `graphql:"avatar @include(if: $withAvatar)"`
+			Login  string `graphql:"login @a @b(reason: \"x:y\")"`
+		} `graphql:"me: user(filter: {name: \"a:b\", tags: [\"(c)\"]}) @skip(if: $anonymous)"`
+		Search []struct {
+			Name string
+		} `graphql:"search(query: \"name:go\") { name }"`
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"me": {"avatar": "gopher.png", "login": "gopher"},
+		"search": [{"name": "go"}]
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.User.Avatar = "gopher.png"
+	want.User.Login = "gopher"
+	want.Search = []struct{ Name string }{{Name: "go"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	// conditional fields may be missing in strict mode
+	var strict query
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"search": []}`), &strict, jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnmarshalGraphQL_namingStrategy(t *testing.T) {
+	type query struct {
+		Viewer struct {
+			ClientMutationID string
+			DatabaseID       int
+			AvatarURL        string `graphql:"avatarUrl"`
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"viewer": {"client_mutation_id": "abc", "database_id": 42, "avatarUrl": "gopher.png"}
+	}`), &got, jsonutil.WithNamingStrategy(ident.SnakeCase), jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Viewer.ClientMutationID = "abc"
+	want.Viewer.DatabaseID = 42
+	want.Viewer.AvatarURL = "gopher.png"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	// snake_case keys aren't known to the default strategy
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"viewer": {"client_mutation_id": "abc"}}`), &got)
+	if err == nil {
+		t.Error("got no error decoding a snake_case key with the default strategy")
+	}
+}
+
+func TestUnmarshalGraphQL_optional(t *testing.T) {
+	var q struct {
+		User struct {
+			Nickname jsonutil.Optional
+			Age      jsonutil.Optional
+			Email    jsonutil.Optional
+			Tags     jsonutil.Optional
+		}
+	}
+	q.User.Age = jsonutil.OmittedOptional(0)
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"user": {"nickname": null, "age": 42, "tags": ["go"]}
+	}`), &q, jsonutil.WithDecodeMode(jsonutil.DecodeModeStrict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.User.Nickname.IsNull() {
+		t.Errorf("got nickname %+v, want null", q.User.Nickname)
+	}
+	if got, want := q.User.Age.Value(), 42; !q.User.Age.IsSet() || got != want {
+		t.Errorf("got age %#v, want %#v", got, want)
+	}
+	if !q.User.Email.IsOmitted() {
+		t.Errorf("got email %+v, want omitted", q.User.Email)
+	}
+	// the value of an Optional without type is generic
+	if got, want := q.User.Tags.Value(), []interface{}{"go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tags %#v, want %#v", got, want)
+	}
+}
+
+// cents is a type without JSON methods, like the types of third-party packages
+type cents struct {
+	value int64
+}
+
+func init() {
+	err := jsonutil.RegisterScalar(cents{}, jsonutil.ScalarConfig{
+		TypeName: "numeric",
+		Marshal: func(v interface{}) ([]byte, error) {
+			c := v.(cents)
+			return []byte(fmt.Sprintf("%d.%02d", c.value/100, c.value%100)), nil
+		},
+		Unmarshal: func(data []byte, v interface{}) error {
+			f, err := strconv.ParseFloat(string(data), 64)
+			if err != nil {
+				return err
+			}
+			v.(*cents).value = int64(math.Round(f * 100))
+			return nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestUnmarshalGraphQL_registeredScalar(t *testing.T) {
+	type query struct {
+		Product struct {
+			Price    cents
+			Discount *cents
+			Refund   *cents
+			History  []cents
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"product": {"price": 12.34, "discount": 1.5, "refund": null, "history": [10, 11.99]}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Product.Price = cents{1234}
+	want.Product.Discount = &cents{150}
+	want.Product.History = []cents{{1000}, {1199}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot:  %+v\nwant: %+v", got, want)
+	}
+
+	b, err := jsonutil.MarshalScalar(cents{1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "12.34"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestRegisterScalar_errors(t *testing.T) {
+	if err := jsonutil.RegisterScalar(nil, jsonutil.ScalarConfig{TypeName: "JSON"}); err == nil {
+		t.Error("got no error registering nil")
+	}
+	if err := jsonutil.RegisterScalar(cents{}, jsonutil.ScalarConfig{TypeName: "numeric!"}); err == nil {
+		t.Error("got no error registering an invalid type name")
+	}
+}
@@ -0,0 +1,114 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+var optionalType = reflect.TypeOf(Optional{})
+
+type optionalState uint8
+
+const (
+	optionalOmitted optionalState = iota
+	optionalNull
+	optionalSet
+)
+
+// Optional is a value that is either omitted, explicitly null, or set,
+// e.g. an input field of an update mutation, where null clears the column and omitted leaves it untouched.
+// The zero value is omitted.
+//
+// The Go type of the value is the nullable GraphQL type of variables. Omitted and null values
+// of NullOptional and OmittedOptional keep the type of the example, the zero value has no type.
+//
+// Decoded Optional fields are omitted if the response doesn't have them, even in strict mode.
+// The value is decoded into the type of the Optional if it has one, otherwise into the generic
+// map[string]interface{}, []interface{}, string, json.Number and bool values
+type Optional struct {
+	value interface{}
+	t     reflect.Type
+	state optionalState
+}
+
+// NewOptional returns the Optional set to v. Nil values, including nil pointers, are null
+func NewOptional(v interface{}) Optional {
+	o := Optional{value: v, t: reflect.TypeOf(v), state: optionalSet}
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		o.value = nil
+		o.state = optionalNull
+	}
+	return o
+}
+
+// NullOptional returns the explicit null Optional of the type of example
+func NullOptional(example interface{}) Optional {
+	return Optional{t: reflect.TypeOf(example), state: optionalNull}
+}
+
+// OmittedOptional returns the omitted Optional of the type of example
+func OmittedOptional(example interface{}) Optional {
+	return Optional{t: reflect.TypeOf(example), state: optionalOmitted}
+}
+
+// IsOmitted reports whether the value is omitted
+func (o Optional) IsOmitted() bool {
+	return o.state == optionalOmitted
+}
+
+// IsNull reports whether the value is explicitly null
+func (o Optional) IsNull() bool {
+	return o.state == optionalNull
+}
+
+// IsSet reports whether the value is set
+func (o Optional) IsSet() bool {
+	return o.state == optionalSet
+}
+
+// Value returns the value if it's set, or nil
+func (o Optional) Value() interface{} {
+	return o.value
+}
+
+// Type returns the Go type of the value, or nil if it's unknown
+func (o Optional) Type() reflect.Type {
+	return o.t
+}
+
+// MarshalJSON encodes the value if it's set, or null.
+// Omitted values are left out of objects by the variables encoder of the client
+func (o Optional) MarshalJSON() ([]byte, error) {
+	if o.state != optionalSet {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.value)
+}
+
+// UnmarshalJSON decodes null or the value into the type of the Optional
+func (o *Optional) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		o.value = nil
+		o.state = optionalNull
+		return nil
+	}
+	if o.t == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		o.value = value
+		o.state = optionalSet
+		return nil
+	}
+	ptr := reflect.New(o.t)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return err
+	}
+	o.value = ptr.Elem().Interface()
+	o.state = optionalSet
+	return nil
+}
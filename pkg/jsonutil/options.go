@@ -0,0 +1,105 @@
+package jsonutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hasura/go-graphql-client/ident"
+)
+
+// DecodeMode controls how differences between the response data and the query struct are handled
+type DecodeMode int
+
+const (
+	// DecodeModeDefault fails on response keys that don't exist in the struct,
+	// and ignores selected fields that are missing from the response
+	DecodeModeDefault DecodeMode = iota
+	// DecodeModeLenient ignores response keys that don't exist in the struct,
+	// e.g. the fields of a wider selection set executed by Exec
+	DecodeModeLenient
+	// DecodeModeStrict fails on response keys that don't exist in the struct,
+	// selected struct fields that are missing from the response, and null values of non-pointer fields
+	DecodeModeStrict
+)
+
+// String returns the name of the decode mode
+func (m DecodeMode) String() string {
+	switch m {
+	case DecodeModeLenient:
+		return "lenient"
+	case DecodeModeStrict:
+		return "strict"
+	default:
+		return "default"
+	}
+}
+
+// DecoderOption configures the behavior of UnmarshalGraphQL
+type DecoderOption func(*decodeOptions)
+
+// WithDecodeMode sets the decode mode
+func WithDecodeMode(mode DecodeMode) DecoderOption {
+	return func(opts *decodeOptions) {
+		opts.mode = mode
+	}
+}
+
+// WithNamingStrategy sets the naming strategy of struct fields without graphql tag,
+// which must be the same as the strategy of the query construction. The default is ident.LowerCamelCase.
+// Field names are matched case-insensitively too, for backward compatibility
+func WithNamingStrategy(naming ident.NamingStrategy) DecoderOption {
+	return func(opts *decodeOptions) {
+		opts.naming = naming
+	}
+}
+
+type decodeOptions struct {
+	mode   DecodeMode
+	naming ident.NamingStrategy
+}
+
+// isNullable reports whether the null value is expected for v.
+// Pointers, interfaces, slices, maps and Optional values are nullable
+func isNullable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	return v.Type() == optionalType
+}
+
+// selectedStructs returns the struct v and its embedded structs, whose fields are selected on the same object.
+// Fields of inline fragments are only selected if the type condition matches, so they are excluded
+func selectedStructs(v reflect.Value, naming ident.NamingStrategy) []reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	structs := []reflect.Value{v}
+	for _, i := range cachedStructInfo(v.Type(), naming).embedded {
+		structs = append(structs, selectedStructs(v.Field(i), naming)...)
+	}
+	return structs
+}
+
+// checkSelectedFields returns an error if a selected field of the structs is missing from the object keys.
+// Fragments, embedded structs, which are checked separately, and conditional fields aren't selected
+func checkSelectedFields(state objectState, naming ident.NamingStrategy) error {
+	for _, v := range state.structs {
+		info := cachedStructInfo(v.Type(), naming)
+		seen := make([]bool, len(info.fields))
+		for key := range state.seen {
+			if i := info.position(key); i >= 0 {
+				seen[i] = true
+			}
+		}
+		for i := range info.fields {
+			if info.fields[i].selected && !seen[i] {
+				return fmt.Errorf("selected field %q of %v is missing from the response", info.fields[i].responseKey, v.Type())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ScalarConfig describes how values of a Go type are named, encoded and decoded as a GraphQL scalar
+type ScalarConfig struct {
+	// TypeName is the GraphQL type of variables, e.g. "timestamptz"
+	TypeName string
+	// Marshal encodes v, a value of the type, to JSON. encoding/json is used if nil
+	Marshal func(v interface{}) ([]byte, error)
+	// Unmarshal decodes JSON data into v, a pointer to a value of the type. encoding/json is used if nil
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+var scalarRegistry = struct {
+	sync.RWMutex
+	scalars map[reflect.Type]ScalarConfig
+}{
+	scalars: map[reflect.Type]ScalarConfig{},
+}
+
+// RegisterScalar registers the type of example as the GraphQL scalar of config, e.g. time.Time as timestamptz,
+// so types that can't implement GraphQLType or json.Unmarshaler work as variables and result fields.
+// Pointers to the type are the nullable scalar. If example is a pointer, its element type is registered.
+//
+// Fields of registered types aren't expanded in queries, and they are decoded by config.Unmarshal.
+// Registering the same type again replaces its config. Register scalars before using them, e.g. in init
+func RegisterScalar(example interface{}, config ScalarConfig) error {
+	t := reflect.TypeOf(example)
+	if t == nil {
+		return errors.New("the example of the scalar is nil")
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isGraphQLName(config.TypeName) {
+		return fmt.Errorf("invalid GraphQL type name %q of scalar %v", config.TypeName, t)
+	}
+
+	scalarRegistry.Lock()
+	scalarRegistry.scalars[t] = config
+	atomic.AddUint64(&registryVersion, 1)
+	scalarRegistry.Unlock()
+
+	// The layouts of structs and the flags of types depend on the registered scalars
+	resetCache(&structInfoCache)
+	resetCache(&typeFlagsCache)
+	return nil
+}
+
+// RegisteredScalar returns the config of the scalar type t, or false if t isn't registered
+func RegisteredScalar(t reflect.Type) (ScalarConfig, bool) {
+	if t == nil {
+		return ScalarConfig{}, false
+	}
+	scalarRegistry.RLock()
+	config, ok := scalarRegistry.scalars[t]
+	scalarRegistry.RUnlock()
+	return config, ok
+}
+
+// isRegisteredScalar reports whether t or its pointer element type is a registered scalar
+func isRegisteredScalar(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	_, ok := RegisteredScalar(t)
+	return ok
+}
+
+// MarshalScalar encodes v to JSON with the config of its registered scalar type, or with encoding/json
+func MarshalScalar(v interface{}) ([]byte, error) {
+	if config, ok := RegisteredScalar(reflect.TypeOf(v)); ok && config.Marshal != nil {
+		return config.Marshal(v)
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalScalar decodes JSON data into v, whose type or pointer element type is a registered scalar.
+// Null sets pointers to nil
+func unmarshalScalar(data []byte, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if isNullToken(json.RawMessage(data)) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := unmarshalScalar(data, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	config, _ := RegisteredScalar(v.Type())
+	ptr := reflect.New(v.Type())
+	if config.Unmarshal != nil {
+		if err := config.Unmarshal(data, ptr.Interface()); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return err
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+func isGraphQLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func resetCache(cache *sync.Map) {
+	cache.Range(func(key, _ interface{}) bool {
+		cache.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,133 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// UnionMember is a concrete Go type of a GraphQL union or interface
+type UnionMember struct {
+	// Typename is the name of the GraphQL object type, the value of __typename
+	Typename string
+	// Type is the Go type that is assigned to the interface field
+	Type reflect.Type
+}
+
+var unionRegistry = struct {
+	sync.RWMutex
+	members map[reflect.Type][]UnionMember
+}{
+	members: map[reflect.Type][]UnionMember{},
+}
+
+// RegisterUnion registers concrete Go types of the GraphQL union or interface that is represented by a Go interface.
+// iface must be a nil pointer to the interface, e.g. (*SearchResult)(nil).
+// types maps __typename to an example value of the concrete type, e.g. {"User": User{}, "Repository": &Repository{}},
+// the type of each example must implement the interface.
+//
+// Fields of the interface type select __typename and an inline fragment per registered type,
+// and they are decoded into the concrete type that matches __typename.
+// Registering the same interface again replaces its members
+func RegisterUnion(iface interface{}, types map[string]interface{}) error {
+	t := reflect.TypeOf(iface)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		return fmt.Errorf("expected a pointer to an interface, got %T", iface)
+	}
+	t = t.Elem()
+	if t.NumMethod() == 0 {
+		return errors.New("the union interface must have methods")
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("no member type of %v", t)
+	}
+
+	members := make([]UnionMember, 0, len(types))
+	for typename, example := range types {
+		if typename == "" {
+			return fmt.Errorf("the typename of the member of %v must not be empty", t)
+		}
+		memberType := reflect.TypeOf(example)
+		if memberType == nil || !memberType.Implements(t) {
+			return fmt.Errorf("%T of %s doesn't implement %v", example, typename, t)
+		}
+		members = append(members, UnionMember{Typename: typename, Type: memberType})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Typename < members[j].Typename
+	})
+
+	unionRegistry.Lock()
+	unionRegistry.members[t] = members
+	atomic.AddUint64(&registryVersion, 1)
+	unionRegistry.Unlock()
+	return nil
+}
+
+// registryVersion is increased by every registration of unions and scalars
+var registryVersion uint64
+
+// RegistryVersion returns a number that changes whenever unions or scalars are registered,
+// so that callers can invalidate what they derived from the registered types, e.g. cached query documents
+func RegistryVersion() uint64 {
+	return atomic.LoadUint64(&registryVersion)
+}
+
+// UnionMembers returns the registered members of the interface type t sorted by typename,
+// or false if t isn't registered
+func UnionMembers(t reflect.Type) ([]UnionMember, bool) {
+	if t == nil || t.Kind() != reflect.Interface {
+		return nil, false
+	}
+	unionRegistry.RLock()
+	members, ok := unionRegistry.members[t]
+	unionRegistry.RUnlock()
+	return members, ok
+}
+
+func isRegisteredUnion(t reflect.Type) bool {
+	_, ok := UnionMembers(t)
+	return ok
+}
+
+// decodeUnion decodes the JSON object data into a new value of the member type that matches __typename
+// and assigns it to the interface v. v is set to nil if __typename isn't registered,
+// so new types added to the server schema don't break the client
+func decodeUnion(data []byte, v reflect.Value, members []UnionMember, opts decodeOptions) error {
+	var head struct {
+		Typename *string `json:"__typename"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return err
+	}
+	if head.Typename == nil {
+		return fmt.Errorf("__typename is required to decode the union %v", v.Type())
+	}
+
+	for _, member := range members {
+		if member.Typename != *head.Typename {
+			continue
+		}
+		var target reflect.Value
+		if member.Type.Kind() == reflect.Ptr {
+			target = reflect.New(member.Type.Elem())
+		} else {
+			target = reflect.New(member.Type)
+		}
+		if err := unmarshalGraphQL(data, target.Interface(), opts, true); err != nil {
+			return wrapDecodeError(err, "", "("+member.Type.String()+")")
+		}
+		if member.Type.Kind() == reflect.Ptr {
+			v.Set(target)
+		} else {
+			v.Set(target.Elem())
+		}
+		return nil
+	}
+	v.Set(reflect.Zero(v.Type()))
+	return nil
+}
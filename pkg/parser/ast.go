@@ -0,0 +1,242 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position represents the line and column of a token in the source document, starting from 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+// SyntaxError represents an error that occurs while parsing a GraphQL document
+type SyntaxError struct {
+	Message  string
+	Position Position
+}
+
+// Error implements error interface.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("graphql syntax error (%d:%d): %s", e.Position.Line, e.Position.Column, e.Message)
+}
+
+// OperationType represents the type of an operation definition
+type OperationType string
+
+const (
+	Query        OperationType = "query"
+	Mutation     OperationType = "mutation"
+	Subscription OperationType = "subscription"
+)
+
+// Document is the root node of an executable GraphQL document.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Document
+type Document struct {
+	Operations []*OperationDefinition
+	Fragments  []*FragmentDefinition
+}
+
+// Operation returns the operation that matches the input name.
+// If the name is empty, the document must contain exactly one operation
+func (d *Document) Operation(name string) (*OperationDefinition, error) {
+	if name == "" {
+		switch len(d.Operations) {
+		case 0:
+			return nil, fmt.Errorf("the document does not contain any operation")
+		case 1:
+			return d.Operations[0], nil
+		default:
+			return nil, fmt.Errorf("the operation name is required because the document contains %d operations", len(d.Operations))
+		}
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation named %q", name)
+}
+
+// Fragment returns the fragment definition by name, or nil if not found
+func (d *Document) Fragment(name string) *FragmentDefinition {
+	for _, f := range d.Fragments {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// OperationDefinition represents a query, mutation or subscription operation
+type OperationDefinition struct {
+	Type                OperationType
+	Name                string
+	VariableDefinitions []*VariableDefinition
+	Directives          []*Directive
+	SelectionSet        SelectionSet
+	Position            Position
+}
+
+// FragmentDefinition represents a named fragment definition
+type FragmentDefinition struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  SelectionSet
+	Position      Position
+}
+
+// VariableDefinition represents a variable declared by an operation
+type VariableDefinition struct {
+	Name         string
+	Type         *Type
+	DefaultValue *Value
+	Directives   []*Directive
+	Position     Position
+}
+
+// Type represents an input type reference, e.g. [String!]!
+type Type struct {
+	// NamedType is the name of the type. It's empty if the type is a list
+	NamedType string
+	// Elem is the element type of the list type
+	Elem    *Type
+	NonNull bool
+}
+
+// String returns the type in GraphQL notation
+func (t *Type) String() string {
+	var s string
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	} else {
+		s = t.NamedType
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Directive represents a directive annotation, e.g. @include(if: $foo)
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+	Position  Position
+}
+
+// Argument represents a named argument of a field or directive
+type Argument struct {
+	Name     string
+	Value    *Value
+	Position Position
+}
+
+// ValueKind represents the kind of an input value
+type ValueKind int
+
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value represents an input value literal or a variable reference
+type Value struct {
+	Kind ValueKind
+	// Raw is the variable name, the decoded string or the source text of the scalar value
+	Raw string
+	// List contains items of the list value
+	List []*Value
+	// Fields contains fields of the object value
+	Fields   []*ObjectField
+	Position Position
+}
+
+// ObjectField represents a field of an input object value
+type ObjectField struct {
+	Name     string
+	Value    *Value
+	Position Position
+}
+
+// Variables returns names of all variables referenced by the value
+func (v *Value) Variables() []string {
+	var names []string
+	switch v.Kind {
+	case VariableValue:
+		names = append(names, v.Raw)
+	case ListValue:
+		for _, item := range v.List {
+			names = append(names, item.Variables()...)
+		}
+	case ObjectValue:
+		for _, f := range v.Fields {
+			names = append(names, f.Value.Variables()...)
+		}
+	}
+	return names
+}
+
+// Selection is the common interface of Field, FragmentSpread and InlineFragment
+type Selection interface {
+	isSelection()
+}
+
+// SelectionSet represents a list of selections of an operation, field or fragment
+type SelectionSet []Selection
+
+// Field represents a field selection
+type Field struct {
+	Alias        string
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet SelectionSet
+	Position     Position
+}
+
+// ResponseKey returns the key of the field in the response object, that is the alias if exists, or the field name
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread represents a named fragment spread, e.g. ...UserFields
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Position   Position
+}
+
+// InlineFragment represents an inline fragment, e.g. ... on User { name }
+type InlineFragment struct {
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  SelectionSet
+	Position      Position
+}
+
+func (*Field) isSelection()          {}
+func (*FragmentSpread) isSelection() {}
+func (*InlineFragment) isSelection() {}
+
+// joinNames joins the list of names to a readable string for error messages
+func joinNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,367 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind represents the lexical token kinds of the GraphQL language
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenBlockString
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "<EOF>"
+	case tokenPunctuator:
+		return "punctuator"
+	case tokenName:
+		return "name"
+	case tokenInt:
+		return "int"
+	case tokenFloat:
+		return "float"
+	case tokenString, tokenBlockString:
+		return "string"
+	}
+	return "unknown"
+}
+
+// token is a single lexical token of the source document.
+// For string tokens, value holds the decoded string. Otherwise it holds the source text.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   Position
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "<EOF>"
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// lexer splits a GraphQL source document into tokens.
+// Whitespace, line terminators, commas and comments are insignificant and skipped.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Language.Source-Text
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	col  int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+// next reads the next significant token
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	start := Position{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.advance(3)
+			return token{kind: tokenPunctuator, value: "...", pos: start}, nil
+		}
+		return token{}, l.errorf(start, "unexpected character %q, did you mean \"...\"?", c)
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokenPunctuator, value: string(c), pos: start}, nil
+	case c == '_' || isLetter(c):
+		begin := l.pos
+		for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+			l.advance(1)
+		}
+		return token{kind: tokenName, value: l.src[begin:l.pos], pos: start}, nil
+	case c == '-' || isDigit(c):
+		return l.readNumber(start)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.readBlockString(start)
+		}
+		return l.readString(start)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, l.errorf(start, "unexpected character %q", r)
+}
+
+// skipIgnored skips the insignificant characters
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == ',':
+			l.advance(1)
+		case c == '\n':
+			l.newLine(1)
+		case c == '\r':
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n' {
+				l.newLine(2)
+			} else {
+				l.newLine(1)
+			}
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.advance(1)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			// skip the unicode byte order mark
+			l.pos += len("\ufeff")
+			l.col++
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) readNumber(start Position) (token, error) {
+	begin := l.pos
+	isFloat := false
+	if l.peekByte() == '-' {
+		l.advance(1)
+	}
+	if l.peekByte() == '0' {
+		l.advance(1)
+		if isDigit(l.peekByte()) {
+			return token{}, l.errorf(start, "invalid number, unexpected digit after 0")
+		}
+	} else if err := l.readDigits(start); err != nil {
+		return token{}, err
+	}
+	if l.peekByte() == '.' {
+		isFloat = true
+		l.advance(1)
+		if err := l.readDigits(start); err != nil {
+			return token{}, err
+		}
+	}
+	if c := l.peekByte(); c == 'e' || c == 'E' {
+		isFloat = true
+		l.advance(1)
+		if c := l.peekByte(); c == '+' || c == '-' {
+			l.advance(1)
+		}
+		if err := l.readDigits(start); err != nil {
+			return token{}, err
+		}
+	}
+	// a name may follow the number directly, e.g. "first:1after:$cursor", which GraphQL servers accept
+	if c := l.peekByte(); c == '.' {
+		return token{}, l.errorf(start, "invalid number, unexpected character %q", c)
+	}
+	kind := tokenInt
+	if isFloat {
+		kind = tokenFloat
+	}
+	return token{kind: kind, value: l.src[begin:l.pos], pos: start}, nil
+}
+
+func (l *lexer) readDigits(start Position) error {
+	if !isDigit(l.peekByte()) {
+		return l.errorf(start, "invalid number, expected digit")
+	}
+	for isDigit(l.peekByte()) {
+		l.advance(1)
+	}
+	return nil
+}
+
+func (l *lexer) readString(start Position) (token, error) {
+	// skip the opening quote
+	l.advance(1)
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return token{kind: tokenString, value: sb.String(), pos: start}, nil
+		case c == '\n' || c == '\r':
+			return token{}, l.errorf(start, "unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, l.errorf(start, "unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			switch esc {
+			case '"', '\\', '/':
+				sb.WriteByte(esc)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+6 > len(l.src) {
+					return token{}, l.errorf(start, "invalid unicode escape sequence")
+				}
+				var r rune
+				for _, h := range l.src[l.pos+2 : l.pos+6] {
+					d, ok := hexValue(h)
+					if !ok {
+						return token{}, l.errorf(start, "invalid unicode escape sequence %q", l.src[l.pos:l.pos+6])
+					}
+					r = r<<4 | d
+				}
+				sb.WriteRune(r)
+				l.advance(4)
+			default:
+				return token{}, l.errorf(start, "invalid escape sequence \\%c", esc)
+			}
+			l.advance(2)
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			sb.WriteRune(r)
+			l.pos += size
+			l.col++
+		}
+	}
+	return token{}, l.errorf(start, "unterminated string")
+}
+
+func (l *lexer) readBlockString(start Position) (token, error) {
+	// skip the opening triple quotes
+	l.advance(3)
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.advance(3)
+			return token{kind: tokenBlockString, value: blockStringValue(sb.String()), pos: start}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			sb.WriteString(`"""`)
+			l.advance(4)
+		case l.src[l.pos] == '\n':
+			sb.WriteByte('\n')
+			l.newLine(1)
+		case l.src[l.pos] == '\r':
+			sb.WriteByte('\n')
+			if l.pos+1 < len(l.src) && l.src[l.pos+1] == '\n' {
+				l.newLine(2)
+			} else {
+				l.newLine(1)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			sb.WriteRune(r)
+			l.pos += size
+			l.col++
+		}
+	}
+	return token{}, l.errorf(start, "unterminated block string")
+}
+
+// blockStringValue removes the common indentation and the leading and trailing blank lines of the raw block string.
+//
+// Specification: https://spec.graphql.org/October2021/#BlockStringValue()
+func blockStringValue(raw string) string {
+	lines := strings.Split(raw, "\n")
+	commonIndent := -1
+	for i, line := range lines {
+		if i == 0 {
+			continue
+		}
+		indent := leadingWhitespace(line)
+		if indent < len(line) && (commonIndent < 0 || indent < commonIndent) {
+			commonIndent = indent
+		}
+	}
+	if commonIndent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= commonIndent {
+				lines[i] = lines[i][commonIndent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && leadingWhitespace(lines[0]) == len(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && leadingWhitespace(lines[len(lines)-1]) == len(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func leadingWhitespace(s string) int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+func (l *lexer) peekByte() byte {
+	if l.pos < len(l.src) {
+		return l.src[l.pos]
+	}
+	return 0
+}
+
+func (l *lexer) advance(n int) {
+	l.pos += n
+	l.col += n
+}
+
+func (l *lexer) newLine(n int) {
+	l.pos += n
+	l.line++
+	l.col = 1
+}
+
+func (l *lexer) errorf(pos Position, format string, args ...interface{}) error {
+	return &SyntaxError{
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return c == '_' || isLetter(c) || isDigit(c)
+}
+
+func hexValue(r rune) (rune, bool) {
+	switch {
+	case r >= '0' && r <= '9':
+		return r - '0', true
+	case r >= 'a' && r <= 'f':
+		return r - 'a' + 10, true
+	case r >= 'A' && r <= 'F':
+		return r - 'A' + 10, true
+	}
+	return 0, false
+}
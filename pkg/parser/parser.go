@@ -0,0 +1,567 @@
+// Package parser provides a parser of executable GraphQL documents
+// that turns the source text into an abstract syntax tree.
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Language
+package parser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Parse parses and validates the syntax of an executable GraphQL document.
+// Type system definitions aren't supported.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: newLexer(source)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDocument(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parser is a recursive descent parser, with one token lookahead
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// peek reports whether the current token is the punctuator
+func (p *parser) peek(punctuator string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == punctuator
+}
+
+// peekKeyword reports whether the current token is the name keyword
+func (p *parser) peekKeyword(keyword string) bool {
+	return p.tok.kind == tokenName && p.tok.value == keyword
+}
+
+// skip consumes the current token if it's the punctuator
+func (p *parser) skip(punctuator string) (bool, error) {
+	if !p.peek(punctuator) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		return p.unexpected(fmt.Sprintf("%q", punctuator))
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected("name")
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.peekKeyword(keyword) {
+		return p.unexpected(fmt.Sprintf("%q", keyword))
+	}
+	return p.advance()
+}
+
+func (p *parser) unexpected(expected string) error {
+	return &SyntaxError{
+		Message:  fmt.Sprintf("expected %s, got %s", expected, p.tok),
+		Position: p.tok.pos,
+	}
+}
+
+func (p *parser) parseDocument() (*Document, error) {
+	doc := &Document{}
+	if p.tok.kind == tokenEOF {
+		return nil, p.unexpected("definition")
+	}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			op, err := p.parseOperationDefinition()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.kind == tokenName:
+			switch p.tok.value {
+			case string(Query), string(Mutation), string(Subscription):
+				op, err := p.parseOperationDefinition()
+				if err != nil {
+					return nil, err
+				}
+				doc.Operations = append(doc.Operations, op)
+			case "fragment":
+				frag, err := p.parseFragmentDefinition()
+				if err != nil {
+					return nil, err
+				}
+				doc.Fragments = append(doc.Fragments, frag)
+			case "schema", "scalar", "type", "interface", "union", "enum", "input", "directive", "extend":
+				return nil, &SyntaxError{
+					Message:  fmt.Sprintf("type system definition %q isn't allowed in executable documents", p.tok.value),
+					Position: p.tok.pos,
+				}
+			default:
+				return nil, p.unexpected("definition")
+			}
+		default:
+			return nil, p.unexpected("definition")
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) parseOperationDefinition() (*OperationDefinition, error) {
+	op := &OperationDefinition{Position: p.tok.pos}
+	// query shorthand
+	if p.peek("{") {
+		op.Type = Query
+		selectionSet, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		op.SelectionSet = selectionSet
+		return op, nil
+	}
+
+	op.Type = OperationType(p.tok.value)
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if p.tok.kind == tokenName {
+		if op.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if op.VariableDefinitions, err = p.parseVariableDefinitions(); err != nil {
+		return nil, err
+	}
+	if op.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	if op.SelectionSet, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) parseFragmentDefinition() (*FragmentDefinition, error) {
+	frag := &FragmentDefinition{Position: p.tok.pos}
+	if err := p.expectKeyword("fragment"); err != nil {
+		return nil, err
+	}
+	if p.peekKeyword("on") {
+		return nil, p.unexpected("fragment name")
+	}
+	var err error
+	if frag.Name, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if err = p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	if frag.TypeCondition, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if frag.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	if frag.SelectionSet, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]*VariableDefinition, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var defs []*VariableDefinition
+	for {
+		if ok, err := p.skip(")"); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		def := &VariableDefinition{Position: p.tok.pos}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var err error
+		if def.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			if def.DefaultValue, err = p.parseValue(true); err != nil {
+				return nil, err
+			}
+		}
+		if def.Directives, err = p.parseDirectives(true); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	if len(defs) == 0 {
+		return nil, p.unexpected("variable definition")
+	}
+	return defs, nil
+}
+
+func (p *parser) parseType() (*Type, error) {
+	t := &Type{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.Elem, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		if t.NamedType, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	ok, err := p.skip("!")
+	if err != nil {
+		return nil, err
+	}
+	t.NonNull = ok
+	return t, nil
+}
+
+func (p *parser) parseDirectives(isConst bool) ([]*Directive, error) {
+	var directives []*Directive
+	for p.peek("@") {
+		d := &Directive{Position: p.tok.pos}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if d.Arguments, err = p.parseArguments(isConst); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func (p *parser) parseArguments(isConst bool) ([]*Argument, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var args []*Argument
+	for {
+		if ok, err := p.skip(")"); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		arg := &Argument{Position: p.tok.pos}
+		var err error
+		if arg.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.Value, err = p.parseValue(isConst); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) == 0 {
+		return nil, p.unexpected("argument")
+	}
+	return args, nil
+}
+
+func (p *parser) parseSelectionSet() (SelectionSet, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.peek("}") {
+		return nil, p.unexpected("selection")
+	}
+	var selections SelectionSet
+	for {
+		if ok, err := p.skip("}"); err != nil {
+			return nil, err
+		} else if ok {
+			return selections, nil
+		}
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+}
+
+func (p *parser) parseSelection() (Selection, error) {
+	if p.peek("...") {
+		return p.parseFragment()
+	}
+	return p.parseField()
+}
+
+func (p *parser) parseField() (*Field, error) {
+	field := &Field{Position: p.tok.pos}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.Alias = name
+		if name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+	if field.Arguments, err = p.parseArguments(false); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if field.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (p *parser) parseFragment() (Selection, error) {
+	selection, err := p.parseFragmentHead()
+	if err != nil {
+		return nil, err
+	}
+	if fragment, ok := selection.(*InlineFragment); ok {
+		if fragment.SelectionSet, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return selection, nil
+}
+
+// parseFragmentHead parses a fragment spread, or an inline fragment without its selection set
+func (p *parser) parseFragmentHead() (Selection, error) {
+	pos := p.tok.pos
+	if err := p.expect("..."); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName && !p.peekKeyword("on") {
+		spread := &FragmentSpread{Position: pos}
+		var err error
+		if spread.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if spread.Directives, err = p.parseDirectives(false); err != nil {
+			return nil, err
+		}
+		return spread, nil
+	}
+
+	fragment := &InlineFragment{Position: pos}
+	var err error
+	if p.peekKeyword("on") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if fragment.TypeCondition, err = p.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if fragment.Directives, err = p.parseDirectives(false); err != nil {
+		return nil, err
+	}
+	return fragment, nil
+}
+
+// parseValue parses an input value. Variables aren't allowed in constant values
+func (p *parser) parseValue(isConst bool) (*Value, error) {
+	tok := p.tok
+	value := &Value{Position: tok.pos, Raw: tok.value}
+	switch tok.kind {
+	case tokenPunctuator:
+		switch tok.value {
+		case "$":
+			if isConst {
+				return nil, &SyntaxError{Message: "unexpected variable in constant value", Position: tok.pos}
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			value.Kind = VariableValue
+			value.Raw = name
+			return value, nil
+		case "[":
+			return p.parseList(isConst)
+		case "{":
+			return p.parseObject(isConst)
+		}
+		return nil, p.unexpected("value")
+	case tokenInt:
+		value.Kind = IntValue
+	case tokenFloat:
+		value.Kind = FloatValue
+	case tokenString, tokenBlockString:
+		value.Kind = StringValue
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			value.Kind = BooleanValue
+		case "null":
+			value.Kind = NullValue
+		default:
+			value.Kind = EnumValue
+		}
+	default:
+		return nil, p.unexpected("value")
+	}
+	return value, p.advance()
+}
+
+func (p *parser) parseList(isConst bool) (*Value, error) {
+	value := &Value{Kind: ListValue, Position: p.tok.pos}
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.skip("]"); err != nil {
+			return nil, err
+		} else if ok {
+			return value, nil
+		}
+		item, err := p.parseValue(isConst)
+		if err != nil {
+			return nil, err
+		}
+		value.List = append(value.List, item)
+	}
+}
+
+func (p *parser) parseObject(isConst bool) (*Value, error) {
+	value := &Value{Kind: ObjectValue, Position: p.tok.pos}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.skip("}"); err != nil {
+			return nil, err
+		} else if ok {
+			return value, nil
+		}
+		field := &ObjectField{Position: p.tok.pos}
+		var err error
+		if field.Name, err = p.expectName(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if field.Value, err = p.parseValue(isConst); err != nil {
+			return nil, err
+		}
+		value.Fields = append(value.Fields, field)
+	}
+}
+
+// validateDocument checks the document-level rules that can be verified without the schema
+//
+// Specification: https://spec.graphql.org/October2021/#sec-Documents
+func validateDocument(doc *Document) error {
+	operationNames := make(map[string]bool)
+	for _, op := range doc.Operations {
+		if op.Name == "" {
+			if len(doc.Operations) > 1 {
+				return &SyntaxError{Message: "anonymous operation must be the only defined operation", Position: op.Position}
+			}
+			continue
+		}
+		if operationNames[op.Name] {
+			return &SyntaxError{Message: fmt.Sprintf("there can be only one operation named %q", op.Name), Position: op.Position}
+		}
+		operationNames[op.Name] = true
+	}
+
+	fragmentNames := make(map[string]bool)
+	for _, frag := range doc.Fragments {
+		if fragmentNames[frag.Name] {
+			return &SyntaxError{Message: fmt.Sprintf("there can be only one fragment named %q", frag.Name), Position: frag.Position}
+		}
+		fragmentNames[frag.Name] = true
+	}
+
+	unknownFragments := make(map[string]bool)
+	for _, op := range doc.Operations {
+		collectUnknownFragments(op.SelectionSet, fragmentNames, unknownFragments)
+	}
+	for _, frag := range doc.Fragments {
+		collectUnknownFragments(frag.SelectionSet, fragmentNames, unknownFragments)
+	}
+	if len(unknownFragments) > 0 {
+		names := make([]string, 0, len(unknownFragments))
+		for name := range unknownFragments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown fragments: %s", joinNames(names))
+	}
+
+	return nil
+}
+
+func collectUnknownFragments(selectionSet SelectionSet, known map[string]bool, unknown map[string]bool) {
+	for _, selection := range selectionSet {
+		switch s := selection.(type) {
+		case *Field:
+			collectUnknownFragments(s.SelectionSet, known, unknown)
+		case *InlineFragment:
+			collectUnknownFragments(s.SelectionSet, known, unknown)
+		case *FragmentSpread:
+			if !known[s.Name] {
+				unknown[s.Name] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package parser_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hasura/go-graphql-client/pkg/parser"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parser.Parse(`
+		# fetch the repository
+		query GetRepository($owner: String!, $first: Int = 10, $labels: [String!]) @cached(ttl: 60) {
+			repository(owner: $owner, name: "go-graphql-client") {
+				id
+				issues: issueList(first: $first, filter: {labels: $labels, states: [OPEN, CLOSED]}) @include(if: true) {
+					...IssueFields
+					... on Issue {
+						body
+					}
+					... @skip(if: false) {
+						title
+					}
+				}
+			}
+		}
+
+		fragment IssueFields on Issue {
+			number
+			description: body
+		}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Operations) != 1 || len(doc.Fragments) != 1 {
+		t.Fatalf("got %d operations and %d fragments, want 1 and 1", len(doc.Operations), len(doc.Fragments))
+	}
+
+	op, err := doc.Operation("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Type != parser.Query || op.Name != "GetRepository" {
+		t.Errorf("got operation: %s %s, want: query GetRepository", op.Type, op.Name)
+	}
+
+	wantVariables := []struct {
+		name, typ string
+	}{
+		{"owner", "String!"},
+		{"first", "Int"},
+		{"labels", "[String!]"},
+	}
+	if len(op.VariableDefinitions) != len(wantVariables) {
+		t.Fatalf("got %d variable definitions, want %d", len(op.VariableDefinitions), len(wantVariables))
+	}
+	for i, want := range wantVariables {
+		def := op.VariableDefinitions[i]
+		if def.Name != want.name || def.Type.String() != want.typ {
+			t.Errorf("variable %d: got $%s: %s, want $%s: %s", i, def.Name, def.Type, want.name, want.typ)
+		}
+	}
+	if def := op.VariableDefinitions[1].DefaultValue; def == nil || def.Kind != parser.IntValue || def.Raw != "10" {
+		t.Errorf("got default value %+v, want 10", def)
+	}
+
+	if len(op.Directives) != 1 || op.Directives[0].Name != "cached" || op.Directives[0].Arguments[0].Value.Raw != "60" {
+		t.Errorf("unexpected operation directives: %+v", op.Directives)
+	}
+
+	repository := op.SelectionSet[0].(*parser.Field)
+	if repository.Name != "repository" || len(repository.Arguments) != 2 {
+		t.Fatalf("unexpected field: %+v", repository)
+	}
+	if v := repository.Arguments[1].Value; v.Kind != parser.StringValue || v.Raw != "go-graphql-client" {
+		t.Errorf("unexpected argument value: %+v", v)
+	}
+
+	issues := repository.SelectionSet[1].(*parser.Field)
+	if issues.ResponseKey() != "issues" || issues.Name != "issueList" {
+		t.Errorf("got field %s: %s, want issues: issueList", issues.Alias, issues.Name)
+	}
+	filter := issues.Arguments[1].Value
+	if filter.Kind != parser.ObjectValue || len(filter.Fields) != 2 {
+		t.Fatalf("unexpected object value: %+v", filter)
+	}
+	if got := strings.Join(filter.Variables(), ","); got != "labels" {
+		t.Errorf("got variables: %s, want: labels", got)
+	}
+	if states := filter.Fields[1].Value; states.Kind != parser.ListValue || len(states.List) != 2 || states.List[0].Kind != parser.EnumValue {
+		t.Errorf("unexpected list value: %+v", states)
+	}
+
+	if spread, ok := issues.SelectionSet[0].(*parser.FragmentSpread); !ok || spread.Name != "IssueFields" {
+		t.Errorf("got %+v, want fragment spread IssueFields", issues.SelectionSet[0])
+	}
+	if inline, ok := issues.SelectionSet[1].(*parser.InlineFragment); !ok || inline.TypeCondition != "Issue" {
+		t.Errorf("got %+v, want inline fragment on Issue", issues.SelectionSet[1])
+	}
+	if inline, ok := issues.SelectionSet[2].(*parser.InlineFragment); !ok || inline.TypeCondition != "" || len(inline.Directives) != 1 {
+		t.Errorf("got %+v, want inline fragment with directive", issues.SelectionSet[2])
+	}
+
+	fragment := doc.Fragment("IssueFields")
+	if fragment == nil || fragment.TypeCondition != "Issue" || len(fragment.SelectionSet) != 2 {
+		t.Errorf("unexpected fragment: %+v", fragment)
+	}
+}
+
+func TestParse_minified(t *testing.T) {
+	// the output format of ConstructQuery
+	doc, err := parser.Parse(`mutation SayHello($msg:String!$ids:[ID!]!)@cached{sayHello(msg:$msg,ids:$ids){id,msg},hello}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Operations[0]
+	if op.Type != parser.Mutation || op.Name != "SayHello" || len(op.VariableDefinitions) != 2 || len(op.SelectionSet) != 2 {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+}
+
+func TestDocument_String(t *testing.T) {
+	doc, err := parser.Parse(`
+		query GetUser($id: ID!, $first: Int = 10) @cached {
+			me: user(id: $id, filter: {name: "a\"b\n", tags: [A, B]}) {
+				...UserFields
+				... on Admin @include(if: true) { role }
+			}
+		}
+		fragment UserFields on User { id, name }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query GetUser($id:ID!,$first:Int=10)@cached{me:user(id:$id,filter:{name:"a\"b\n",tags:[A,B]}){...UserFields,... on Admin@include(if:true){role}}}fragment UserFields on User{id,name}`
+	if got := doc.String(); got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+	// the printed document is parsed to the same output
+	reparsed, err := parser.Parse(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reparsed.String(); got != want {
+		t.Errorf("\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestParse_strings(t *testing.T) {
+	doc, err := parser.Parse(`{
+		a: echo(msg: "line\n\"quoted\" \u00e9")
+		b: echo(msg: """
+			first
+			  second
+		""")
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := doc.Operations[0]
+	want := []string{
+		"line\n\"quoted\" é",
+		"first\n  second",
+	}
+	for i, w := range want {
+		got := op.SelectionSet[i].(*parser.Field).Arguments[0].Value.Raw
+		if got != w {
+			t.Errorf("%d: got %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestDocument_Operation(t *testing.T) {
+	doc, err := parser.Parse(`query A { a } mutation B { b } subscription C { c }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		op, err := doc.Operation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Name != name {
+			t.Errorf("got operation %s, want %s", op.Name, name)
+		}
+	}
+	if _, err := doc.Operation(""); err == nil {
+		t.Error("expected error for multiple operations without name, got nil")
+	}
+	if _, err := doc.Operation("D"); err == nil {
+		t.Error("expected error for unknown operation, got nil")
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	fixtures := []struct {
+		input string
+		want  string
+	}{
+		{"", "graphql syntax error (1:1): expected definition, got <EOF>"},
+		{"{}", "graphql syntax error (1:2): expected selection, got \"}\""},
+		{"{ user { id }", "graphql syntax error (1:14): expected name, got <EOF>"},
+		{"query { user(id: ) { id } }", "graphql syntax error (1:18): expected value, got \")\""},
+		{"query ($id) { user }", "graphql syntax error (1:11): expected \":\", got \")\""},
+		{"query ($id: ID = $other) { user }", "graphql syntax error (1:18): unexpected variable in constant value"},
+		{"{ user(name: \"abc) }", "graphql syntax error (1:14): unterminated string"},
+		{"{ user(limit: 01) }", "graphql syntax error (1:15): invalid number, unexpected digit after 0"},
+		{"{ user ? }", "graphql syntax error (1:8): unexpected character '?'"},
+		{"type User { id: ID }", "graphql syntax error (1:1): type system definition \"type\" isn't allowed in executable documents"},
+		{"{ a } { b }", "graphql syntax error (1:1): anonymous operation must be the only defined operation"},
+		{"query A { a } query A { b }", "graphql syntax error (1:15): there can be only one operation named \"A\""},
+		{"fragment on on User { id }", "graphql syntax error (1:10): expected fragment name, got \"on\""},
+		{"{ ...UserFields, ...Missing } fragment UserFields on User { id }", "unknown fragments: \"Missing\""},
+	}
+
+	for _, f := range fixtures {
+		_, err := parser.Parse(f.input)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", f.input)
+			continue
+		}
+		if err.Error() != f.want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", f.input, err, f.want)
+		}
+	}
+
+	_, err := parser.Parse("{ user(")
+	var syntaxErr *parser.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected SyntaxError, got %T", err)
+	}
+	if syntaxErr.Position.Line != 1 || syntaxErr.Position.Column != 8 {
+		t.Errorf("got position %+v, want 1:8", syntaxErr.Position)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	fixtures := []struct {
+		tag           string
+		kind          parser.TagKind
+		alias         string
+		name          string
+		arguments     string
+		directives    string
+		typeCondition string
+		responseKey   string
+	}{
+		{tag: "login", kind: parser.FieldTag, name: "login", responseKey: "login"},
+		{
+			tag:         `me: user(id: $id, filter: {name: "a:b", labels: [BUG, "x(y)"]})`,
+			kind:        parser.FieldTag,
+			alias:       "me",
+			name:        "user",
+			arguments:   `id:$id,filter:{name:"a:b",labels:[BUG,"x(y)"]}`,
+			responseKey: "me",
+		},
+		{
+			tag:         "avatar @include(if: $withAvatar) @deprecated",
+			kind:        parser.FieldTag,
+			name:        "avatar",
+			directives:  "@include(if:$withAvatar)@deprecated",
+			responseKey: "avatar",
+		},
+		{
+			tag:         `comments(first:1after:"Y3Vyc29y")`,
+			kind:        parser.FieldTag,
+			name:        "comments",
+			arguments:   `first:1,after:"Y3Vyc29y"`,
+			responseKey: "comments",
+		},
+		{tag: "... on User", kind: parser.InlineFragmentTag, typeCondition: "User"},
+		{tag: "... @skip(if: $skip)", kind: parser.InlineFragmentTag, directives: "@skip(if:$skip)"},
+		{tag: "...UserFields", kind: parser.FragmentSpreadTag, name: "UserFields"},
+	}
+
+	for _, f := range fixtures {
+		tag, err := parser.ParseTag(f.tag)
+		if err != nil {
+			t.Errorf("%s: %v", f.tag, err)
+			continue
+		}
+		var args, directives []string
+		for _, arg := range tag.Arguments {
+			args = append(args, arg.Name+":"+arg.Value.String())
+		}
+		for _, d := range tag.Directives {
+			directive := "@" + d.Name
+			var dargs []string
+			for _, arg := range d.Arguments {
+				dargs = append(dargs, arg.Name+":"+arg.Value.String())
+			}
+			if len(dargs) > 0 {
+				directive += "(" + strings.Join(dargs, ",") + ")"
+			}
+			directives = append(directives, directive)
+		}
+		got := []string{tag.Alias, tag.Name, strings.Join(args, ","), strings.Join(directives, ""), tag.TypeCondition, tag.ResponseKey()}
+		want := []string{f.alias, f.name, f.arguments, f.directives, f.typeCondition, f.responseKey}
+		if tag.Kind != f.kind || strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s:\ngot:  %d %q\nwant: %d %q", f.tag, tag.Kind, got, f.kind, want)
+		}
+	}
+
+	tag, err := parser.ParseTag("viewer { login, ... on Admin { role } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.SelectionSet) != 2 {
+		t.Errorf("got %d selections, want 2", len(tag.SelectionSet))
+	}
+	if tag.Directive("include") != nil {
+		t.Error("got unexpected directive")
+	}
+}
+
+func TestParseTag_errors(t *testing.T) {
+	fixtures := []struct {
+		input string
+		want  string
+	}{
+		{"", "graphql syntax error (1:1): expected field or fragment, got <EOF>"},
+		{"user(", "graphql syntax error (1:6): expected name, got <EOF>"},
+		{"user()", "graphql syntax error (1:7): expected argument, got <EOF>"},
+		{"me: ", "graphql syntax error (1:5): expected name, got <EOF>"},
+		{"... on", "graphql syntax error (1:7): expected name, got <EOF>"},
+		{"login name", "graphql syntax error (1:7): expected end of tag, got \"name\""},
+		{"user(first: 1.5.0)", "graphql syntax error (1:13): invalid number, unexpected character '.'"},
+		{"...UserFields { id }", "graphql syntax error (1:15): expected end of tag, got \"{\""},
+	}
+
+	for _, f := range fixtures {
+		_, err := parser.ParseTag(f.input)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", f.input)
+			continue
+		}
+		if err.Error() != f.want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", f.input, err, f.want)
+		}
+	}
+}
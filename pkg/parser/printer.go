@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the minified GraphQL source of the document
+func (d *Document) String() string {
+	var sb strings.Builder
+	for _, op := range d.Operations {
+		op.print(&sb)
+	}
+	for _, frag := range d.Fragments {
+		frag.print(&sb)
+	}
+	return sb.String()
+}
+
+// String returns the minified GraphQL source of the operation
+func (op *OperationDefinition) String() string {
+	var sb strings.Builder
+	op.print(&sb)
+	return sb.String()
+}
+
+func (op *OperationDefinition) print(sb *strings.Builder) {
+	if op.Name == "" && len(op.VariableDefinitions) == 0 && len(op.Directives) == 0 && op.Type == Query {
+		op.SelectionSet.print(sb)
+		return
+	}
+	sb.WriteString(string(op.Type))
+	if op.Name != "" {
+		sb.WriteString(" ")
+		sb.WriteString(op.Name)
+	}
+	if len(op.VariableDefinitions) > 0 {
+		sb.WriteString("(")
+		for i, def := range op.VariableDefinitions {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("$")
+			sb.WriteString(def.Name)
+			sb.WriteString(":")
+			sb.WriteString(def.Type.String())
+			if def.DefaultValue != nil {
+				sb.WriteString("=")
+				def.DefaultValue.print(sb)
+			}
+			printDirectives(sb, def.Directives)
+		}
+		sb.WriteString(")")
+	}
+	printDirectives(sb, op.Directives)
+	op.SelectionSet.print(sb)
+}
+
+// String returns the minified GraphQL source of the fragment definition
+func (frag *FragmentDefinition) String() string {
+	var sb strings.Builder
+	frag.print(&sb)
+	return sb.String()
+}
+
+func (frag *FragmentDefinition) print(sb *strings.Builder) {
+	sb.WriteString("fragment ")
+	sb.WriteString(frag.Name)
+	sb.WriteString(" on ")
+	sb.WriteString(frag.TypeCondition)
+	printDirectives(sb, frag.Directives)
+	frag.SelectionSet.print(sb)
+}
+
+// String returns the minified GraphQL source of the selection set
+func (ss SelectionSet) String() string {
+	var sb strings.Builder
+	ss.print(&sb)
+	return sb.String()
+}
+
+func (ss SelectionSet) print(sb *strings.Builder) {
+	if len(ss) == 0 {
+		return
+	}
+	sb.WriteString("{")
+	for i, selection := range ss {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		switch s := selection.(type) {
+		case *Field:
+			if s.Alias != "" {
+				sb.WriteString(s.Alias)
+				sb.WriteString(":")
+			}
+			sb.WriteString(s.Name)
+			printArguments(sb, s.Arguments)
+			printDirectives(sb, s.Directives)
+			s.SelectionSet.print(sb)
+		case *FragmentSpread:
+			sb.WriteString("...")
+			sb.WriteString(s.Name)
+			printDirectives(sb, s.Directives)
+		case *InlineFragment:
+			sb.WriteString("...")
+			if s.TypeCondition != "" {
+				sb.WriteString(" on ")
+				sb.WriteString(s.TypeCondition)
+			}
+			printDirectives(sb, s.Directives)
+			s.SelectionSet.print(sb)
+		}
+	}
+	sb.WriteString("}")
+}
+
+func printDirectives(sb *strings.Builder, directives []*Directive) {
+	for _, d := range directives {
+		sb.WriteString("@")
+		sb.WriteString(d.Name)
+		printArguments(sb, d.Arguments)
+	}
+}
+
+func printArguments(sb *strings.Builder, args []*Argument) {
+	if len(args) == 0 {
+		return
+	}
+	sb.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(arg.Name)
+		sb.WriteString(":")
+		arg.Value.print(sb)
+	}
+	sb.WriteString(")")
+}
+
+// String returns the GraphQL source of the value
+func (v *Value) String() string {
+	var sb strings.Builder
+	v.print(&sb)
+	return sb.String()
+}
+
+func (v *Value) print(sb *strings.Builder) {
+	switch v.Kind {
+	case VariableValue:
+		sb.WriteString("$")
+		sb.WriteString(v.Raw)
+	case StringValue:
+		sb.WriteString(QuoteString(v.Raw))
+	case ListValue:
+		sb.WriteString("[")
+		for i, item := range v.List {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			item.print(sb)
+		}
+		sb.WriteString("]")
+	case ObjectValue:
+		sb.WriteString("{")
+		for i, f := range v.Fields {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(f.Name)
+			sb.WriteString(":")
+			f.Value.print(sb)
+		}
+		sb.WriteString("}")
+	default:
+		sb.WriteString(v.Raw)
+	}
+}
+
+// QuoteString returns a double-quoted GraphQL string literal of s
+func QuoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
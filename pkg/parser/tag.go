@@ -0,0 +1,100 @@
+package parser
+
+// TagKind represents the kind of selection that a graphql struct tag declares
+type TagKind int
+
+const (
+	// FieldTag declares a field, e.g. `me: user(id: $id) @include(if: $withUser)`
+	FieldTag TagKind = iota
+	// InlineFragmentTag declares an inline fragment, e.g. `... on User`
+	InlineFragmentTag
+	// FragmentSpreadTag declares a named fragment spread, e.g. `...UserFields`
+	FragmentSpreadTag
+)
+
+// Tag represents the parsed value of a graphql struct tag
+type Tag struct {
+	Kind TagKind
+	// Alias is the alias of the field, if exists
+	Alias string
+	// Name is the field name, or the name of the spread fragment
+	Name       string
+	Arguments  []*Argument
+	Directives []*Directive
+	// TypeCondition is the type condition of the inline fragment, it's empty if omitted
+	TypeCondition string
+	// SelectionSet is the optional selection set written in the tag, e.g. of a raw JSON field
+	SelectionSet SelectionSet
+}
+
+// ResponseKey returns the key of the field in the response object, that is the alias if exists, or the field name.
+// Fragments don't have a response key, their fields are in the object of the parent
+func (t *Tag) ResponseKey() string {
+	if t.Kind != FieldTag {
+		return ""
+	}
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.Name
+}
+
+// Directive returns the directive of the tag with the name, or nil if none found
+func (t *Tag) Directive(name string) *Directive {
+	for _, d := range t.Directives {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// ParseTag parses the value of a graphql struct tag, which declares a single selection:
+// a field with optional alias, arguments and directives, an inline fragment or a fragment spread.
+// The selection set is optional, it's usually derived from the type of the struct field
+func ParseTag(tag string) (*Tag, error) {
+	p := &parser{lexer: newLexer(tag)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenEOF {
+		return nil, p.unexpected("field or fragment")
+	}
+
+	var result *Tag
+	if p.peek("...") {
+		selection, err := p.parseFragmentHead()
+		if err != nil {
+			return nil, err
+		}
+		switch s := selection.(type) {
+		case *FragmentSpread:
+			result = &Tag{Kind: FragmentSpreadTag, Name: s.Name, Directives: s.Directives}
+		case *InlineFragment:
+			result = &Tag{Kind: InlineFragmentTag, TypeCondition: s.TypeCondition, Directives: s.Directives}
+		}
+		if result.Kind == InlineFragmentTag && p.peek("{") {
+			var err error
+			if result.SelectionSet, err = p.parseSelectionSet(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		result = &Tag{
+			Kind:         FieldTag,
+			Alias:        field.Alias,
+			Name:         field.Name,
+			Arguments:    field.Arguments,
+			Directives:   field.Directives,
+			SelectionSet: field.SelectionSet,
+		}
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected("end of tag")
+	}
+	return result, nil
+}
@@ -3,6 +3,7 @@ package graphql
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -11,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
+	"github.com/hasura/go-graphql-client/pkg/parser"
 )
 
 type constructOptionsOutput struct {
@@ -44,66 +47,104 @@ func constructOptions(options []Option) (*constructOptionsOutput, error) {
 }
 
 // ConstructQuery build GraphQL query string from struct and variables
-func ConstructQuery(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query, err := query(v)
+func ConstructQuery(v interface{}, variables interface{}, options ...Option) (string, error) {
+	vars, err := variablesMap(variables)
 	if err != nil {
 		return "", err
 	}
+	query, _, err := constructOperation(queryOperation, v, vars, options, nil)
+	return query, err
+}
 
-	optionsOutput, err := constructOptions(options)
+// ConstructQuery build GraphQL mutation string from struct and variables
+func ConstructMutation(v interface{}, variables interface{}, options ...Option) (string, error) {
+	vars, err := variablesMap(variables)
 	if err != nil {
 		return "", err
 	}
+	query, _, err := constructOperation(mutationOperation, v, vars, options, nil)
+	return query, err
+}
 
-	if len(variables) > 0 {
-		return fmt.Sprintf("query %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+// ConstructSubscription build GraphQL subscription string from struct and variables
+func ConstructSubscription(v interface{}, variables interface{}, options ...Option) (string, string, error) {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return "", "", err
 	}
+	return constructOperation(subscriptionOperation, v, vars, options, nil)
+}
 
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return query, nil
+// constructOperation builds the GraphQL document of the operation from struct and variables.
+// Named fragment definitions are appended after the operation.
+// Untagged struct fields are named by naming, ident.LowerCamelCase if nil.
+// It returns the document and the operation name
+//
+// Documents of types that don't depend on values are cached by the type, the variable types, the options and the naming strategy.
+func constructOperation(op operationType, v interface{}, variables map[string]interface{}, options []Option, naming ident.NamingStrategy) (string, string, error) {
+	key, cacheable := newQueryCacheKey(op, v, variables, options, naming)
+	if cacheable {
+		if cached, ok := loadCachedQuery(key); ok {
+			return cached.query, cached.operationName, nil
+		}
 	}
 
-	return fmt.Sprintf("query %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
-}
-
-// ConstructQuery build GraphQL mutation string from struct and variables
-func ConstructMutation(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query, err := query(v)
+	query, fragments, err := query(v, naming)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	optionsOutput, err := constructOptions(options)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	document := formatOperation(op, optionsOutput, variables, query, fragments)
+	if cacheable {
+		storeCachedQuery(key, cachedQuery{query: document, operationName: optionsOutput.operationName})
 	}
+	return document, optionsOutput.operationName, nil
+}
+
+// formatOperation joins the operation header, the selection set and the fragment definitions
+func formatOperation(op operationType, optionsOutput *constructOptionsOutput, variables map[string]interface{}, query string, fragments string) string {
+	keyword := op.String()
 	if len(variables) > 0 {
-		return fmt.Sprintf("mutation %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+		return fmt.Sprintf("%s %s(%s)%s%s%s", keyword, optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query, fragments)
 	}
 
 	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "mutation" + query, nil
+		// the query keyword can be omitted in the shorthand form
+		if op == queryOperation {
+			keyword = ""
+		}
+		return keyword + query + fragments
 	}
 
-	return fmt.Sprintf("mutation %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+	return fmt.Sprintf("%s %s%s%s%s", keyword, optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query, fragments)
 }
 
-// ConstructSubscription build GraphQL subscription string from struct and variables
-func ConstructSubscription(v interface{}, variables map[string]interface{}, options ...Option) (string, string, error) {
-	query, err := query(v)
+// parseExecOperationName validates the syntax of a pre-built query document
+// and returns the name of the operation that will be executed.
+// The operation is selected by the OperationName option, otherwise the document must contain a single operation.
+// The subscription client executes operations of any type, e.g. queries over graphql-ws,
+// but subscription operations must be executed by the subscription client
+func parseExecOperationName(query string, subscription bool, options []Option) (string, error) {
+	optionsOutput, err := constructOptions(options)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
-	optionsOutput, err := constructOptions(options)
+	doc, err := parser.Parse(query)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
-	if len(variables) > 0 {
-		return fmt.Sprintf("subscription %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), optionsOutput.operationName, nil
+	op, err := doc.Operation(optionsOutput.operationName)
+	if err != nil {
+		return "", err
 	}
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "subscription" + query, optionsOutput.operationName, nil
+	if !subscription && op.Type == parser.Subscription {
+		return "", errors.New("subscription operations must be executed by the subscription client")
 	}
-	return fmt.Sprintf("subscription %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), optionsOutput.operationName, nil
+	return op.Name, nil
 }
 
 // queryArguments constructs a minified arguments string for variables.
@@ -123,6 +164,11 @@ func queryArguments(variables map[string]interface{}) string {
 		io.WriteString(&buf, "$")
 		io.WriteString(&buf, k)
 		io.WriteString(&buf, ":")
+		if v, ok := variables[k].(typedVariable); ok {
+			// the definition of a variables struct field
+			io.WriteString(&buf, v.definition)
+			continue
+		}
 		writeArgumentType(&buf, reflect.TypeOf(variables[k]), variables[k], true)
 		// Don't insert a comma here.
 		// Commas in GraphQL are insignificant, and we want minified output.
@@ -135,19 +181,23 @@ func queryArguments(variables map[string]interface{}) string {
 // value indicates whether t is a value (required) type or pointer (optional) type.
 // If value is true, then "!" is written at the end of t.
 func writeArgumentType(w io.Writer, t reflect.Type, v interface{}, value bool) {
+	if t == optionalType {
+		// Optional is a nullable type of its value.
+		writeOptionalType(w, v)
+		return
+	}
+	if config, ok := jsonutil.RegisteredScalar(t); ok {
+		io.WriteString(w, config.TypeName)
+		if value {
+			// Value is a required type, so add "!" to the end.
+			io.WriteString(w, "!")
+		}
+		return
+	}
 
 	if t.Implements(graphqlTypeInterface) {
-		var graphqlType GraphQLType
-		var ok bool
 		value = t.Kind() != reflect.Ptr
-		if v != nil {
-			graphqlType, ok = v.(GraphQLType)
-		} else if t.Kind() == reflect.Ptr {
-			graphqlType, ok = reflect.New(t.Elem()).Interface().(GraphQLType)
-		} else {
-			graphqlType, ok = reflect.Zero(t).Interface().(GraphQLType)
-		}
-		if ok {
+		if graphqlType, ok := graphQLTypeOf(t, v); ok {
 			io.WriteString(w, graphqlType.GetGraphQLType())
 			if value {
 				// Value is a required type, so add "!" to the end.
@@ -192,23 +242,75 @@ func writeArgumentType(w io.Writer, t reflect.Type, v interface{}, value bool) {
 
 // query uses writeQuery to recursively construct
 // a minified query string from the provided struct v.
+// The definitions of named fragments used by v are returned separately.
 //
 // E.g., struct{Foo Int, BarBaz *bool} -> "{foo,barBaz}".
-func query(v interface{}) (string, error) {
+func query(v interface{}, naming ident.NamingStrategy) (string, string, error) {
 	var buf bytes.Buffer
-	err := writeQuery(&buf, reflect.TypeOf(v), reflect.ValueOf(v), false)
+	qw := &queryWriter{naming: naming}
+	err := qw.writeQuery(&buf, reflect.TypeOf(v), reflect.ValueOf(v), false)
 	if err != nil {
-		return "", fmt.Errorf("failed to write query: %w", err)
+		return "", "", fmt.Errorf("failed to write query: %w", err)
+	}
+	return buf.String(), qw.fragmentsString(), nil
+}
+
+// queryWriter holds the state of a single query construction
+type queryWriter struct {
+	// named fragment definitions in order of appearance
+	fragments []fragmentDefinition
+	// naming names untagged struct fields, ident.LowerCamelCase if nil
+	naming ident.NamingStrategy
+}
+
+type fragmentDefinition struct {
+	name          string
+	typeCondition string
+	t             reflect.Type
+	body          string
+}
+
+// fieldName returns the GraphQL name of the untagged struct field
+func (qw *queryWriter) fieldName(goName string) string {
+	if qw.naming == nil {
+		return ident.LowerCamelCase.FieldName(goName)
+	}
+	return qw.naming.FieldName(goName)
+}
+
+// fragmentsString returns the minified definitions of all named fragments
+func (qw *queryWriter) fragmentsString() string {
+	var sb strings.Builder
+	for _, f := range qw.fragments {
+		sb.WriteString("fragment ")
+		sb.WriteString(f.name)
+		sb.WriteString(" on ")
+		sb.WriteString(f.typeCondition)
+		sb.WriteString(f.body)
 	}
-	return buf.String(), nil
+	return sb.String()
 }
 
 // writeQuery writes a minified query for t to w.
 // If inline is true, the struct fields of t are inlined into parent struct.
-func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) error {
+func (qw *queryWriter) writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) error {
+	if _, ok := jsonutil.RegisteredScalar(t); ok {
+		// Registered scalars aren't expanded.
+		return nil
+	}
 	switch t.Kind() {
 	case reflect.Ptr:
-		err := writeQuery(w, t.Elem(), ElemSafe(v), false)
+		if t == queryBuilderType {
+			var b *QueryBuilder
+			if v.IsValid() {
+				b, _ = v.Interface().(*QueryBuilder)
+			}
+			if b == nil {
+				return errors.New("the query builder is nil")
+			}
+			return b.write(w)
+		}
+		err := qw.writeQuery(w, t.Elem(), ElemSafe(v), inline)
 		if err != nil {
 			return fmt.Errorf("failed to write query for ptr `%v`: %w", t, err)
 		}
@@ -220,45 +322,24 @@ func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) error
 		if t.AssignableTo(idType) {
 			return nil
 		}
-		if !inline {
-			io.WriteString(w, "{")
-		}
-		iter := 0
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			value, ok := f.Tag.Lookup("graphql")
-			// Skip this field if the tag value is hyphen
-			if value == "-" {
-				continue
+		if name, typeCondition, ok := graphqlFragmentOf(t); ok {
+			if err := qw.defineFragment(t, v, name, typeCondition); err != nil {
+				return err
 			}
-			if iter != 0 {
-				io.WriteString(w, ",")
-			}
-			iter++
-
-			inlineField := f.Anonymous && !ok
-			if !inlineField {
-				if ok {
-					io.WriteString(w, value)
-				} else {
-					io.WriteString(w, ident.ParseMixedCaps(f.Name).ToLowerCamelCase())
-				}
-			}
-			// Skip writeQuery if the GraphQL type associated with the filed is scalar
-			if isTrue(f.Tag.Get("scalar")) {
-				continue
+			if !inline {
+				io.WriteString(w, "{")
 			}
-			err := writeQuery(w, f.Type, FieldSafe(v, i), inlineField)
-			if err != nil {
-				return fmt.Errorf("failed to write query for struct field `%v`: %w", f.Name, err)
+			io.WriteString(w, "...")
+			io.WriteString(w, name)
+			if !inline {
+				io.WriteString(w, "}")
 			}
+			return nil
 		}
-		if !inline {
-			io.WriteString(w, "}")
-		}
+		return qw.writeStruct(w, t, v, inline)
 	case reflect.Slice:
 		if t.Elem().Kind() != reflect.Array {
-			err := writeQuery(w, t.Elem(), IndexSafe(v, 0), false)
+			err := qw.writeQuery(w, t.Elem(), IndexSafe(v, 0), false)
 			if err != nil {
 				return fmt.Errorf("failed to write query for slice item `%v`: %w", t, err)
 			}
@@ -280,19 +361,193 @@ func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) error
 				return fmt.Errorf("expected pair (string, %v), got (%v, %v)",
 					val.Type(), key.Type(), val.Type())
 			}
+			if _, err := parser.ParseTag(keyString); err != nil {
+				return fmt.Errorf("invalid key %q of ordered map: %w", keyString, err)
+			}
 			_, _ = io.WriteString(w, keyString)
-			err := writeQuery(w, val.Type(), val, false)
+			err := qw.writeQuery(w, val.Type(), val, false)
 			if err != nil {
 				return fmt.Errorf("failed to write query for pair[1] `%v`: %w", val.Type(), err)
 			}
 		}
 		_, _ = io.WriteString(w, "}")
+	case reflect.Interface:
+		members, ok := jsonutil.UnionMembers(t)
+		if !ok {
+			return nil
+		}
+		return qw.writeUnion(w, t, members, inline)
 	case reflect.Map:
-		return fmt.Errorf("type %v is not supported, use [][2]interface{} instead", t)
+		if t.Key().Kind() != reflect.String {
+			return fmt.Errorf("type %v is not supported, map keys must be strings", t)
+		}
+		if !v.IsValid() || v.Len() == 0 {
+			return fmt.Errorf("the selection set of map %v is empty, add fields as map keys or tag the field as scalar", t)
+		}
+		// Sort keys in order to produce deterministic output.
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		io.WriteString(w, "{")
+		for i, key := range keys {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			if _, err := parser.ParseTag(key.String()); err != nil {
+				return fmt.Errorf("invalid map key %q: %w", key.String(), err)
+			}
+			io.WriteString(w, key.String())
+			// nil values are leaf fields, otherwise the value is the template of the selection set
+			val := reflect.ValueOf(v.MapIndex(key).Interface())
+			if !val.IsValid() {
+				continue
+			}
+			err := qw.writeQuery(w, val.Type(), val, false)
+			if err != nil {
+				return fmt.Errorf("failed to write query for map key %q: %w", key.String(), err)
+			}
+		}
+		io.WriteString(w, "}")
+	}
+	return nil
+}
+
+// writeStruct writes the selection set of struct fields of t to w.
+// If inline is true, the struct fields of t are inlined into parent struct.
+func (qw *queryWriter) writeStruct(w io.Writer, t reflect.Type, v reflect.Value, inline bool) error {
+	if !inline {
+		io.WriteString(w, "{")
+	}
+	iter := 0
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		value, ok := f.Tag.Lookup("graphql")
+		// Skip this field if the tag value is hyphen
+		if value == "-" {
+			continue
+		}
+		if iter != 0 {
+			io.WriteString(w, ",")
+		}
+		iter++
+
+		inlineField := f.Anonymous && !ok
+		if !inlineField {
+			if ok {
+				if _, err := parser.ParseTag(value); err != nil {
+					return fmt.Errorf("invalid graphql tag of struct field `%v`: %w", f.Name, err)
+				}
+				io.WriteString(w, value)
+			} else {
+				io.WriteString(w, qw.fieldName(f.Name))
+			}
+		}
+		// Skip writeQuery if the GraphQL type associated with the filed is scalar
+		if isTrue(f.Tag.Get("scalar")) {
+			continue
+		}
+		err := qw.writeQuery(w, f.Type, FieldSafe(v, i), inlineField)
+		if err != nil {
+			return fmt.Errorf("failed to write query for struct field `%v`: %w", f.Name, err)
+		}
+	}
+	if !inline {
+		io.WriteString(w, "}")
+	}
+	return nil
+}
+
+// writeUnion writes __typename and an inline fragment of each registered member of the union interface t
+func (qw *queryWriter) writeUnion(w io.Writer, t reflect.Type, members []jsonutil.UnionMember, inline bool) error {
+	if !inline {
+		io.WriteString(w, "{")
+	}
+	io.WriteString(w, "__typename")
+	for _, member := range members {
+		var buf bytes.Buffer
+		err := qw.writeQuery(&buf, member.Type, reflect.Value{}, false)
+		if err != nil {
+			return fmt.Errorf("failed to write query for %s of union `%v`: %w", member.Typename, t, err)
+		}
+		// members without fields only need __typename
+		if buf.Len() == 0 || buf.String() == "{}" {
+			continue
+		}
+		io.WriteString(w, ",... on ")
+		io.WriteString(w, member.Typename)
+		buf.WriteTo(w)
+	}
+	if !inline {
+		io.WriteString(w, "}")
+	}
+	return nil
+}
+
+// defineFragment renders the definition of the named fragment of struct type t, once per query
+func (qw *queryWriter) defineFragment(t reflect.Type, v reflect.Value, name string, typeCondition string) error {
+	for _, f := range qw.fragments {
+		if f.name != name {
+			continue
+		}
+		if f.t != t {
+			return fmt.Errorf("fragment %s is declared by both %v and %v", name, f.t, t)
+		}
+		return nil
+	}
+	if name == "" || typeCondition == "" {
+		return fmt.Errorf("the fragment name and type condition of %v must not be empty", t)
+	}
+
+	// register the fragment before rendering the body to stop recursive fragments
+	index := len(qw.fragments)
+	qw.fragments = append(qw.fragments, fragmentDefinition{
+		name:          name,
+		typeCondition: typeCondition,
+		t:             t,
+	})
+	var buf bytes.Buffer
+	if err := qw.writeStruct(&buf, t, v, false); err != nil {
+		return fmt.Errorf("failed to write fragment %s: %w", name, err)
 	}
+	qw.fragments[index].body = buf.String()
 	return nil
 }
 
+// graphqlFragmentOf returns the named fragment declaration of struct type t if t implements GraphQLFragment
+func graphqlFragmentOf(t reflect.Type) (string, string, bool) {
+	var fragment GraphQLFragment
+	var ok bool
+	if t.Implements(graphqlFragmentInterface) {
+		fragment, ok = reflect.Zero(t).Interface().(GraphQLFragment)
+	} else if reflect.PtrTo(t).Implements(graphqlFragmentInterface) {
+		fragment, ok = reflect.New(t).Interface().(GraphQLFragment)
+	}
+	if !ok {
+		return "", "", false
+	}
+	name, typeCondition := fragment.GetGraphQLFragment()
+	// the method may be promoted from an embedded fragment,
+	// the struct type isn't a fragment itself in that case
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		if embeddedName, _, ok := graphqlFragmentOf(ft); ok && embeddedName == name {
+			return "", "", false
+		}
+	}
+	return name, typeCondition, true
+}
+
 func IndexSafe(v reflect.Value, i int) reflect.Value {
 	if v.IsValid() && i < v.Len() {
 		return v.Index(i)
@@ -314,9 +569,30 @@ func FieldSafe(valStruct reflect.Value, i int) reflect.Value {
 	return reflect.ValueOf(nil)
 }
 
+// graphQLTypeOf returns v, or a value of type t if v is nil, as GraphQLType.
+// Nil pointers are replaced by new values, so value receivers of GetGraphQLType don't panic
+func graphQLTypeOf(t reflect.Type, v interface{}) (GraphQLType, bool) {
+	if t == nil {
+		return nil, false
+	}
+	if v != nil {
+		if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || !rv.IsNil() {
+			graphqlType, ok := v.(GraphQLType)
+			return graphqlType, ok
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		graphqlType, ok := reflect.New(t.Elem()).Interface().(GraphQLType)
+		return graphqlType, ok
+	}
+	graphqlType, ok := reflect.Zero(t).Interface().(GraphQLType)
+	return graphqlType, ok
+}
+
 var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
 var idType = reflect.TypeOf(ID(""))
 var graphqlTypeInterface = reflect.TypeOf((*GraphQLType)(nil)).Elem()
+var graphqlFragmentInterface = reflect.TypeOf((*GraphQLFragment)(nil)).Elem()
 
 func isTrue(s string) bool {
 	b, _ := strconv.ParseBool(s)
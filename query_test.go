@@ -1,6 +1,7 @@
 package graphql
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
@@ -649,6 +650,632 @@ func TestConstructSubscription(t *testing.T) {
 	}
 }
 
+type userFields struct {
+	ID   ID
+	Name string
+}
+
+func (userFields) GetGraphQLFragment() (string, string) { return "UserFields", "User" }
+
+type repositoryFields struct {
+	Name  string
+	Owner userFields
+}
+
+func (*repositoryFields) GetGraphQLFragment() (string, string) {
+	return "RepositoryFields", "Repository"
+}
+
+type conflictUserFields struct {
+	Login string
+}
+
+func (conflictUserFields) GetGraphQLFragment() (string, string) { return "UserFields", "User" }
+
+func TestConstructQuery_namedFragments(t *testing.T) {
+	tests := []struct {
+		options     []Option
+		inV         interface{}
+		inVariables map[string]interface{}
+		want        string
+	}{
+		{
+			inV: struct {
+				Viewer userFields
+				User   struct {
+					userFields
+					Email string
+				} `graphql:"user(login:$login)"`
+			}{},
+			inVariables: map[string]interface{}{
+				"login": "gopher",
+			},
+			want: `query ($login:String!){viewer{...UserFields},user(login:$login){...UserFields,email}}fragment UserFields on User{id,name}`,
+		},
+		{
+			inV: struct {
+				Repository struct {
+					*repositoryFields
+					Collaborators []userFields
+				} `graphql:"repository(name:\"go-graphql-client\")"`
+			}{},
+			want: `{repository(name:"go-graphql-client"){...RepositoryFields,collaborators{...UserFields}}}fragment RepositoryFields on Repository{name,owner{...UserFields}}fragment UserFields on User{id,name}`,
+		},
+		{
+			options: []Option{OperationName("GetViewer")},
+			inV: struct {
+				Viewer *userFields
+			}{},
+			want: `query GetViewer{viewer{...UserFields}}fragment UserFields on User{id,name}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := ConstructQuery(tc.inV, tc.inVariables, tc.options...)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+
+	_, err := ConstructQuery(struct {
+		Viewer userFields
+		User   conflictUserFields
+	}{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "fragment UserFields is declared by both graphql.userFields and graphql.conflictUserFields") {
+		t.Errorf("expected fragment conflict error, got: %v", err)
+	}
+}
+
+func TestConstructMergedQuery(t *testing.T) {
+	type userQuery struct {
+		User userFields `graphql:"user(id: $id)"`
+	}
+	items := []MergeItem{
+		{Query: &userQuery{}, Variables: map[string]interface{}{"id": ID("1")}},
+		{Query: &userQuery{}, Variables: map[string]interface{}{"id": ID("2")}},
+		{Query: &struct {
+			Me struct {
+				Name string
+			} `graphql:"me: viewer @include(if: $withViewer)"`
+			Repository struct {
+				Name string
+			} `graphql:"repository(filter: {owners: [$owner, \"hasura\"]})"`
+		}{}, Variables: map[string]interface{}{"withViewer": true, "owner": "gopher"}},
+	}
+
+	got, variables, err := ConstructMergedQuery(items, OperationName("GetUsers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query GetUsers($q0_id:ID!$q1_id:ID!$q2_owner:String!$q2_withViewer:Boolean!){q0_user:user(id:$q0_id){...UserFields},q1_user:user(id:$q1_id){...UserFields},q2_me:viewer@include(if:$q2_withViewer){name},q2_repository:repository(filter:{owners:[$q2_owner,"hasura"]}){name}}fragment UserFields on User{id,name}`
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+	if len(variables) != 4 || variables["q1_id"] != ID("2") || variables["q2_owner"] != "gopher" {
+		t.Errorf("unexpected variables: %v", variables)
+	}
+
+	got, _, err = ConstructMergedMutation([]MergeItem{
+		{Query: &struct {
+			Hello string `graphql:"hello(msg: \"a\")"`
+		}{}},
+		{Query: &struct {
+			Hello string `graphql:"hello(msg: \"b\")"`
+		}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `mutation{q0_hello:hello(msg:"a"),q1_hello:hello(msg:"b")}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	if _, _, err := ConstructMergedQuery(nil); err == nil {
+		t.Error("expected error for empty items, got nil")
+	}
+	_, _, err = ConstructMergedQuery([]MergeItem{{Query: &struct {
+		userFields
+	}{}}})
+	if err == nil || !strings.Contains(err.Error(), "named fragment UserFields can't be spread at the root of a merged operation") {
+		t.Errorf("expected root fragment spread error, got: %v", err)
+	}
+}
+
+func TestMergedOperation_unmarshal(t *testing.T) {
+	var zeta, alpha struct {
+		ID int
+	}
+	query := [][2]interface{}{{"zeta", &zeta}, {"alpha", &alpha}}
+	items := []MergeItem{{Query: &query}}
+	merged, err := mergeOperations(queryOperation, items, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields are decoded in the order of the response, so the first invalid field is reported
+	err = merged.unmarshal([]byte(`{"q0_zeta":{"id":"1"},"q0_alpha":{"id":"2"}}`), items, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "query 0: failed to decode zeta.id") {
+		t.Errorf("got error: %v, want the error of zeta.id", err)
+	}
+
+	if err := merged.unmarshal([]byte(`null`), items, nil); err != nil {
+		t.Errorf("got error: %v, want: nil", err)
+	}
+}
+
+func TestConstructQuery_builder(t *testing.T) {
+	q := NewQueryBuilder()
+	user := q.Field("user").Alias("me").Arg("id", Var("id")).Directive("include", map[string]interface{}{"if": Var("withUser")})
+	user.Fields("id", "name")
+	user.Field("repositories").
+		Arg("first", 10).
+		Arg("orderBy", map[string]interface{}{"field": EnumValue("CREATED_AT"), "direction": EnumValue("DESC")}).
+		Arg("topics", []string{"go", "graphql \"client\""}).
+		Arg("after", nil).
+		Field("nodes").Fields("name")
+	user.On("Admin").Fields("role")
+	q.On("").Directive("skip", map[string]interface{}{"if": true}).Fields("version")
+
+	got, err := ConstructQuery(q, map[string]interface{}{
+		"id":       ID("1"),
+		"withUser": true,
+	}, OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query GetUser($id:ID!$withUser:Boolean!){me:user(id:$id)@include(if:$withUser){id,name,repositories(first:10,orderBy:{direction:DESC,field:CREATED_AT},topics:["go","graphql \"client\""],after:null){nodes{name}},... on Admin{role}},...@skip(if:true){version}}`
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	// the builder can be used as a field of a struct query
+	got, err = ConstructQuery(struct {
+		Viewer *QueryBuilder
+	}{Viewer: func() *QueryBuilder {
+		b := NewQueryBuilder()
+		b.Fields("login")
+		return b
+	}()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{viewer{login}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	errorFixtures := []struct {
+		build func(q *QueryBuilder)
+		want  string
+	}{
+		{func(q *QueryBuilder) {}, "the selection set is empty"},
+		{func(q *QueryBuilder) { q.Field("user name") }, `invalid field name "user name"`},
+		{func(q *QueryBuilder) { q.Field("user").Arg("id", Var("$id")) }, `field user: argument id: invalid variable name "$id"`},
+		{func(q *QueryBuilder) { q.Field("user").Arg("filter", struct{}{}) }, "field user: argument filter: unsupported value type struct {}"},
+		{func(q *QueryBuilder) { q.On("User") }, "inline fragment on User: the selection set is empty"},
+	}
+	for _, f := range errorFixtures {
+		q := NewQueryBuilder()
+		f.build(q)
+		_, err := ConstructQuery(q, nil)
+		if err == nil || !strings.HasSuffix(err.Error(), f.want) {
+			t.Errorf("got error: %v, want: %s", err, f.want)
+		}
+	}
+}
+
+func TestConstructQuery_map(t *testing.T) {
+	viewer := NewQueryBuilder()
+	viewer.Fields("login")
+	got, err := ConstructQuery(map[string]interface{}{
+		"user(id: $id)": struct {
+			Name string
+		}{},
+		"viewer":       viewer,
+		"__typename":   nil,
+		"... on Query": map[string]interface{}{"version": nil},
+		"repositories": []map[string]interface{}{{"name": nil}},
+	}, map[string]interface{}{"id": ID("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query ($id:ID!){... on Query{version},__typename,repositories{name},user(id: $id){name},viewer{login}}`
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	_, err = ConstructQuery(struct {
+		Meta map[string]interface{}
+	}{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "the selection set of map map[string]interface {} is empty") {
+		t.Errorf("expected empty map error, got: %v", err)
+	}
+	_, err = ConstructQuery(map[int]interface{}{1: nil}, nil)
+	if err == nil || !strings.Contains(err.Error(), "map keys must be strings") {
+		t.Errorf("expected map key error, got: %v", err)
+	}
+}
+
+type timelineItem interface {
+	isTimelineItem()
+}
+
+type closedEvent struct {
+	Actor struct {
+		Login string
+	}
+	CreatedAt DateTime
+}
+
+func (closedEvent) isTimelineItem() {}
+
+type reopenedEvent struct{}
+
+func (*reopenedEvent) isTimelineItem() {}
+
+type commitItem struct {
+	userFields
+}
+
+func (commitItem) isTimelineItem() {}
+
+func TestConstructQuery_registeredUnion(t *testing.T) {
+	if err := RegisterUnion((*timelineItem)(nil), map[string]interface{}{
+		"ClosedEvent":   closedEvent{},
+		"ReopenedEvent": &reopenedEvent{},
+		"Commit":        commitItem{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ConstructQuery(struct {
+		Timeline []timelineItem `graphql:"timeline(first: 10)"`
+		Latest   timelineItem
+	}{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{timeline(first: 10){__typename,... on ClosedEvent{actor{login},createdAt},... on Commit{...UserFields}},latest{__typename,... on ClosedEvent{actor{login},createdAt},... on Commit{...UserFields}}}fragment UserFields on User{id,name}`
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+type cacheEvent interface {
+	isCacheEvent()
+}
+
+type cacheOpened struct {
+	Title string
+}
+
+func (cacheOpened) isCacheEvent() {}
+
+type cacheClosed struct {
+	Reason string
+}
+
+func (cacheClosed) isCacheEvent() {}
+
+func TestConstructQuery_cache(t *testing.T) {
+	type query struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	variables := map[string]interface{}{"id": ID("1")}
+	want := `query ($id:ID!){user(id: $id){name}}`
+	for i := 0; i < 2; i++ {
+		got, err := ConstructQuery(&query{}, variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+		}
+	}
+	key, ok := newQueryCacheKey(queryOperation, &query{}, variables, nil, nil)
+	if !ok {
+		t.Fatal("the query type should be cacheable")
+	}
+	if cached, ok := loadCachedQuery(key); !ok || cached.query != want {
+		t.Errorf("the query isn't cached: %+v", cached)
+	}
+
+	// the variable types and the options are part of the key
+	got, err := ConstructQuery(&query{}, map[string]interface{}{"id": Int(1)}, OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query GetUser($id:Int!){user(id: $id){name}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+
+	// documents that depend on values aren't cached
+	for _, v := range []interface{}{
+		map[string]interface{}{"viewer": nil},
+		[][2]interface{}{{"viewer", nil}},
+		NewQueryBuilder(),
+		struct {
+			Nodes []map[string]interface{}
+		}{},
+	} {
+		if _, ok := newQueryCacheKey(queryOperation, v, nil, nil, nil); ok {
+			t.Errorf("%T should not be cacheable", v)
+		}
+	}
+
+	// registering the union again changes the document
+	type eventsQuery struct {
+		Events []cacheEvent
+	}
+	if err := RegisterUnion((*cacheEvent)(nil), map[string]interface{}{"Opened": cacheOpened{}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ConstructQuery(eventsQuery{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{events{__typename,... on Opened{title}}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+	if err := RegisterUnion((*cacheEvent)(nil), map[string]interface{}{"Opened": cacheOpened{}, "Closed": cacheClosed{}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ConstructQuery(eventsQuery{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{events{__typename,... on Closed{reason},... on Opened{title}}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+}
+
+func TestConstructQuery_invalidTag(t *testing.T) {
+	fixtures := []struct {
+		v    interface{}
+		want string
+	}{
+		{
+			v: struct {
+				User struct {
+					Name string
+				} `graphql:"user(id: )"`
+			}{},
+			want: "failed to write query: invalid graphql tag of struct field `User`: graphql syntax error (1:10): expected value, got \")\"",
+		},
+		{
+			v: struct {
+				Viewer struct {
+					Login string `graphql:"login name"`
+				}
+			}{},
+			want: "failed to write query: failed to write query for struct field `Viewer`: invalid graphql tag of struct field `Login`: graphql syntax error (1:7): expected end of tag, got \"name\"",
+		},
+		{
+			v:    [][2]interface{}{{"... on", nil}},
+			want: "failed to write query: invalid key \"... on\" of ordered map: graphql syntax error (1:7): expected name, got <EOF>",
+		},
+	}
+	for _, f := range fixtures {
+		_, err := ConstructQuery(f.v, nil)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("got error: %v\nwant: %s", err, f.want)
+		}
+	}
+}
+
+func TestConstructQuery_variablesStruct(t *testing.T) {
+	type UserFilter struct {
+		Name string `json:"name"`
+	}
+	type pagination struct {
+		First int `graphql:"first,nullable" default:"10"`
+		After *string
+	}
+	type variables struct {
+		ID      string `graphql:"id" type:"ID!"`
+		Filter  *UserFilter
+		Tags    []string `graphql:"tags,nullable"`
+		Ignored string   `graphql:"-"`
+		pagination
+	}
+	var q struct {
+		Users []struct {
+			Name string
+		} `graphql:"users(id: $id, filter: $filter, tags: $tags, first: $first, after: $after)"`
+	}
+	vars := variables{ID: "1", Tags: []string{"go"}}
+	got, err := ConstructQuery(&q, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query ($after:String$filter:UserFilter$first:Int=10$id:ID!$tags:[String!]){users(id: $id, filter: $filter, tags: $tags, first: $first, after: $after){name}}`
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+	// pointers are accepted too, and the definition doesn't depend on values
+	if got, err := ConstructQuery(&q, &variables{}); err != nil || got != want {
+		t.Errorf("got: %q, %v\nwant: %q", got, err, want)
+	}
+
+	m, err := variablesMap(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"after":null,"filter":null,"first":0,"id":"1","tags":["go"]}`; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+}
+
+func TestConstructQuery_invalidVariables(t *testing.T) {
+	fixtures := []struct {
+		variables interface{}
+		want      string
+	}{
+		{
+			variables: []string{"id"},
+			want:      "variables must be a map or a struct, got []string",
+		},
+		{
+			variables: map[int]interface{}{1: "id"},
+			want:      "the keys of variables must be strings, got map[int]interface {}",
+		},
+		{
+			variables: struct {
+				ID string `graphql:"id" type:"ID!!"`
+			}{},
+			want: "invalid variable field `ID`: graphql syntax error (1:14): expected \"$\", got \"!\"",
+		},
+		{
+			variables: struct {
+				First int `default:"$first"`
+			}{},
+			want: "invalid variable field `First`: graphql syntax error (1:16): unexpected variable in constant value",
+		},
+		{
+			variables: struct {
+				ID  string `graphql:"id"`
+				Key string `graphql:"id"`
+			}{},
+			want: "duplicate variable \"id\" of field `Key`",
+		},
+		{
+			variables: struct {
+				ID string `graphql:"id,required"`
+			}{},
+			want: "invalid option \"required\" of variable field `ID`",
+		},
+		{
+			variables: struct {
+				Value interface{}
+			}{},
+			want: "can't derive the GraphQL type of variable \"value\" from nil interface, set the type tag",
+		},
+		{
+			variables: struct {
+				Point struct{ X, Y int }
+			}{},
+			want: "can't derive the GraphQL type of variable \"point\" from struct { X int; Y int }, set the type tag",
+		},
+	}
+	for _, f := range fixtures {
+		_, err := ConstructQuery(struct{ Viewer struct{ Login string } }{}, f.variables)
+		if err == nil || err.Error() != f.want {
+			t.Errorf("got error: %v\nwant: %s", err, f.want)
+		}
+	}
+}
+
+func TestOptional_variables(t *testing.T) {
+	type usersSetInput struct {
+		Name     Optional   `json:"name"`
+		Nickname Optional   `json:"nickname"`
+		Age      Optional   `json:"age"`
+		Email    string     `json:"email,omitempty"`
+		Tags     []Optional `json:"tags"`
+	}
+	variables := map[string]interface{}{
+		"set": usersSetInput{
+			Name:     NewOptional("Gopher"),
+			Nickname: NullOptional(""),
+			Tags:     []Optional{NewOptional("go"), {}},
+		},
+		"id":       NewOptional(ID("1")),
+		"nickname": NullOptional(""),
+		"limit":    OmittedOptional(0),
+	}
+	got := queryArguments(variables)
+	want := "$id:ID$limit:Int$nickname:String$set:usersSetInput!"
+	if got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+
+	b, err := json.Marshal(encodeVariables(variables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"id":"1","nickname":null,"set":{"name":"Gopher","nickname":null,"tags":["go",null]}}`; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+	if _, ok := variables["limit"]; !ok {
+		t.Error("the variables of the caller are modified")
+	}
+
+	// Optional fields of variables structs
+	type updateVariables struct {
+		ID    string   `graphql:"id" type:"ID!"`
+		Name  Optional `graphql:"name"`
+		Limit Optional `graphql:"limit" type:"Int"`
+	}
+	vars, err := variablesMap(updateVariables{ID: "1", Name: NewOptional("Gopher")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := queryArguments(vars), "$id:ID!$limit:Int$name:String"; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+	b, err = json.Marshal(encodeVariables(vars))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"id":"1","name":"Gopher"}`; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+	if _, err := variablesMap(struct{ Name Optional }{}); err == nil {
+		t.Error("got no error deriving the type of the zero Optional")
+	}
+}
+
+// timestamp is a type without GraphQL methods, like the types of third-party packages
+type timestamp struct {
+	unix int64
+}
+
+func TestRegisterScalar(t *testing.T) {
+	err := RegisterScalar(timestamp{}, ScalarConfig{
+		TypeName: "timestamptz",
+		Marshal: func(v interface{}) ([]byte, error) {
+			return json.Marshal(time.Unix(v.(timestamp).unix, 0).UTC().Format(time.RFC3339))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var q struct {
+		Events []struct {
+			CreatedAt timestamp
+		} `graphql:"events(since: $since, until: $until)"`
+	}
+	variables := map[string]interface{}{
+		"since": timestamp{0},
+		"until": (*timestamp)(nil),
+	}
+	got, err := ConstructQuery(&q, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `query ($since:timestamptz!$until:timestamptz){events(since: $since, until: $until){createdAt}}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q", got, want)
+	}
+
+	type filter struct {
+		After timestamp `json:"after"`
+	}
+	variables["filter"] = &filter{After: timestamp{86400}}
+	b, err := json.Marshal(encodeVariables(variables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"filter":{"after":"1970-01-02T00:00:00Z"},"since":"1970-01-01T00:00:00Z","until":null}`; got != want {
+		t.Errorf("got JSON: %s, want: %s", got, want)
+	}
+}
+
 func TestQueryArguments(t *testing.T) {
 	iVal := int(123)
 	i8Val := int8(12)
@@ -0,0 +1,74 @@
+package scalars
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// BigInt is an arbitrary-precision integer. It's encoded as an exact JSON number,
+// or a string, see SetStringifyNumbers, and decoded from both. The zero value is 0
+type BigInt struct {
+	i *big.Int
+}
+
+// NewBigInt returns the BigInt of a copy of x. Nil is 0
+func NewBigInt(x *big.Int) BigInt {
+	if x == nil {
+		return BigInt{}
+	}
+	return BigInt{i: new(big.Int).Set(x)}
+}
+
+// NewBigIntFromInt64 returns the BigInt of x
+func NewBigIntFromInt64(x int64) BigInt {
+	return BigInt{i: big.NewInt(x)}
+}
+
+// ParseBigInt parses the decimal integer s
+func ParseBigInt(s string) (BigInt, error) {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return BigInt{}, fmt.Errorf("invalid big integer %q", s)
+	}
+	return BigInt{i: i}, nil
+}
+
+// GetGraphQLType returns the GraphQL type name of BigInt, see SetTypeNames
+func (BigInt) GetGraphQLType() string {
+	return GetTypeNames().BigInt
+}
+
+// Int returns a copy of the integer
+func (b BigInt) Int() *big.Int {
+	if b.i == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(b.i)
+}
+
+// String returns the integer in decimal
+func (b BigInt) String() string {
+	if b.i == nil {
+		return "0"
+	}
+	return b.i.String()
+}
+
+// MarshalJSON encodes the integer as a JSON number, or a string, see SetStringifyNumbers
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return marshalNumber(b.String()), nil
+}
+
+// UnmarshalJSON decodes the integer from a JSON number or string. Null is a no-op
+func (b *BigInt) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	s, _ := unquote(data)
+	parsed, err := ParseBigInt(string(s))
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
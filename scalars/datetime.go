@@ -0,0 +1,73 @@
+package scalars
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// dateTimeLayouts are the accepted layouts of DateTime values, the first is used for encoding.
+// Values without offsets are in UTC, e.g. PostgreSQL timestamp columns
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// DateTime is an instant with the offset of its time zone, encoded in RFC 3339 format
+// with fractional seconds, e.g. "2022-03-04T05:06:07.123456+07:00".
+// The offset of decoded values is kept, use UTC or In to convert them
+type DateTime struct {
+	time.Time
+}
+
+// NewDateTime returns the DateTime of t
+func NewDateTime(t time.Time) DateTime {
+	return DateTime{Time: t}
+}
+
+// ParseDateTime parses the RFC 3339 string s. The space separator and offsets without minutes,
+// e.g. "2022-03-04 05:06:07+07", are accepted too, and values without offsets are in UTC
+func ParseDateTime(s string) (DateTime, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return DateTime{Time: t}, nil
+		}
+	}
+	return DateTime{}, fmt.Errorf("invalid date time %q", s)
+}
+
+// GetGraphQLType returns the GraphQL type name of DateTime, see SetTypeNames
+func (DateTime) GetGraphQLType() string {
+	return GetTypeNames().DateTime
+}
+
+// String returns the time in RFC 3339 format
+func (t DateTime) String() string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// MarshalJSON encodes the time in RFC 3339 format with its offset
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON decodes the time string, see ParseDateTime. Null is a no-op
+func (t *DateTime) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDateTime(s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
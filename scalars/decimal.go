@@ -0,0 +1,89 @@
+package scalars
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// decimalPattern is the grammar of JSON numbers
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Decimal is an arbitrary-precision decimal number, e.g. of numeric columns.
+// It keeps the literal of the number, so no precision is lost by converting it to floating-point.
+// It's encoded as a JSON number, or a string, see SetStringifyNumbers, and decoded from both. The zero value is 0
+type Decimal struct {
+	s string
+}
+
+// ParseDecimal parses the decimal number s, e.g. "-12.345" or "1.5e-7"
+func ParseDecimal(s string) (Decimal, error) {
+	if !decimalPattern.MatchString(s) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{s: s}, nil
+}
+
+// NewDecimalFromInt64 returns the Decimal of x
+func NewDecimalFromInt64(x int64) Decimal {
+	return Decimal{s: strconv.FormatInt(x, 10)}
+}
+
+// NewDecimalFromRat returns the Decimal of x rounded to scale digits after the decimal point
+func NewDecimalFromRat(x *big.Rat, scale int) Decimal {
+	return Decimal{s: x.FloatString(scale)}
+}
+
+// GetGraphQLType returns the GraphQL type name of Decimal, see SetTypeNames
+func (Decimal) GetGraphQLType() string {
+	return GetTypeNames().Decimal
+}
+
+// Rat returns the exact value of the number
+func (d Decimal) Rat() *big.Rat {
+	r, ok := new(big.Rat).SetString(d.String())
+	if !ok {
+		// the literal is validated by ParseDecimal
+		return new(big.Rat)
+	}
+	return r
+}
+
+// Float64 returns the nearest floating-point value of the number
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// IsZero reports whether the number is 0
+func (d Decimal) IsZero() bool {
+	return d.Rat().Sign() == 0
+}
+
+// String returns the literal of the number
+func (d Decimal) String() string {
+	if d.s == "" {
+		return "0"
+	}
+	return d.s
+}
+
+// MarshalJSON encodes the number as a JSON number, or a string, see SetStringifyNumbers
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return marshalNumber(d.String()), nil
+}
+
+// UnmarshalJSON decodes the number from a JSON number or string. Null is a no-op
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	s, _ := unquote(data)
+	parsed, err := ParseDecimal(string(s))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
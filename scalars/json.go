@@ -0,0 +1,60 @@
+package scalars
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// JSON is an arbitrary JSON value, e.g. of jsonb columns, kept as the raw encoding.
+// The empty JSON is encoded as null
+type JSON json.RawMessage
+
+// NewJSON encodes v to JSON
+func NewJSON(v interface{}) (JSON, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSON(b), nil
+}
+
+// GetGraphQLType returns the GraphQL type name of JSON, see SetTypeNames
+func (JSON) GetGraphQLType() string {
+	return GetTypeNames().JSON
+}
+
+// Unmarshal decodes the JSON value into v
+func (j JSON) Unmarshal(v interface{}) error {
+	if len(j) == 0 {
+		return errors.New("the JSON value is empty")
+	}
+	return json.Unmarshal(j, v)
+}
+
+// IsNull reports whether the value is empty or null
+func (j JSON) IsNull() bool {
+	return len(j) == 0 || isNull(j)
+}
+
+// String returns the raw JSON
+func (j JSON) String() string {
+	return string(j)
+}
+
+// MarshalJSON returns the raw JSON, or null if it's empty
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return nullLiteral, nil
+	}
+	return json.RawMessage(j).MarshalJSON()
+}
+
+// UnmarshalJSON copies the raw JSON, including null
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	if j == nil {
+		return errors.New("scalars.JSON: UnmarshalJSON on nil pointer")
+	}
+	*j = append((*j)[:0], bytes.TrimSpace(data)...)
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package scalars provides types of common custom GraphQL scalars: DateTime, Date, JSON, BigInt, UUID and Decimal.
+//
+// The types implement the GraphQLType interface of the client and the JSON encoding of the scalars,
+// so they work as variables and result fields. Their GraphQL type names are configured once for all types,
+// e.g. SetTypeNames(HasuraTypeNames) for Hasura schemas.
+package scalars
+
+import (
+	"bytes"
+	"sync"
+)
+
+// TypeNames are the GraphQL type names of the scalar types
+type TypeNames struct {
+	DateTime string
+	Date     string
+	JSON     string
+	BigInt   string
+	UUID     string
+	Decimal  string
+}
+
+var (
+	// DefaultTypeNames are the names of the scalars in most schemas, e.g. of Apollo servers
+	DefaultTypeNames = TypeNames{
+		DateTime: "DateTime",
+		Date:     "Date",
+		JSON:     "JSON",
+		BigInt:   "BigInt",
+		UUID:     "UUID",
+		Decimal:  "Decimal",
+	}
+	// HasuraTypeNames are the names of the PostgreSQL scalars in Hasura schemas
+	HasuraTypeNames = TypeNames{
+		DateTime: "timestamptz",
+		Date:     "date",
+		JSON:     "jsonb",
+		BigInt:   "bigint",
+		UUID:     "uuid",
+		Decimal:  "numeric",
+	}
+)
+
+var typeNames = struct {
+	sync.RWMutex
+	TypeNames
+	stringifyNumbers bool
+}{
+	TypeNames: DefaultTypeNames,
+}
+
+// SetTypeNames sets the GraphQL type names of the scalar types. Empty names keep the current names.
+// Set the names before constructing queries, e.g. in init
+func SetTypeNames(names TypeNames) {
+	typeNames.Lock()
+	defer typeNames.Unlock()
+	current := &typeNames.TypeNames
+	for _, name := range []struct {
+		dst *string
+		src string
+	}{
+		{&current.DateTime, names.DateTime},
+		{&current.Date, names.Date},
+		{&current.JSON, names.JSON},
+		{&current.BigInt, names.BigInt},
+		{&current.UUID, names.UUID},
+		{&current.Decimal, names.Decimal},
+	} {
+		if name.src != "" {
+			*name.dst = name.src
+		}
+	}
+}
+
+// GetTypeNames returns the current GraphQL type names of the scalar types
+func GetTypeNames() TypeNames {
+	typeNames.RLock()
+	defer typeNames.RUnlock()
+	return typeNames.TypeNames
+}
+
+// SetStringifyNumbers sets whether BigInt and Decimal values are encoded as JSON strings instead of numbers.
+// Numbers are exact, but servers that parse JSON numbers as doubles, e.g. JavaScript servers,
+// lose the precision of big numbers. Decoding accepts both
+func SetStringifyNumbers(stringify bool) {
+	typeNames.Lock()
+	defer typeNames.Unlock()
+	typeNames.stringifyNumbers = stringify
+}
+
+func stringifyNumbers() bool {
+	typeNames.RLock()
+	defer typeNames.RUnlock()
+	return typeNames.stringifyNumbers
+}
+
+// marshalNumber encodes the number literal s as a JSON number, or a string, see SetStringifyNumbers
+func marshalNumber(s string) []byte {
+	if stringifyNumbers() {
+		return []byte(`"` + s + `"`)
+	}
+	return []byte(s)
+}
+
+var nullLiteral = []byte("null")
+
+func isNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), nullLiteral)
+}
+
+// unquote returns the content of the JSON string data, or data itself if it isn't a string,
+// so numbers can be decoded from both JSON numbers and strings
+func unquote(data []byte) ([]byte, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		return data[1 : len(data)-1], true
+	}
+	return data, false
+}
@@ -0,0 +1,244 @@
+package scalars_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	"github.com/hasura/go-graphql-client/scalars"
+)
+
+func TestDateTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"2022-03-04T05:06:07.123456+07:00"`, `"2022-03-04T05:06:07.123456+07:00"`},
+		{`"2022-03-04T05:06:07Z"`, `"2022-03-04T05:06:07Z"`},
+		{`"2022-03-04 05:06:07.5+07"`, `"2022-03-04T05:06:07.5+07:00"`},
+		{`"2022-03-04T05:06:07"`, `"2022-03-04T05:06:07Z"`},
+	}
+	for _, tc := range tests {
+		var got scalars.DateTime
+		if err := json.Unmarshal([]byte(tc.in), &got); err != nil {
+			t.Fatalf("%s: %v", tc.in, err)
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.in, b, tc.want)
+		}
+	}
+
+	got, err := scalars.ParseDateTime("2022-03-04T05:06:07+07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2022, 3, 3, 22, 6, 7, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, offset := got.Zone(); offset != 7*60*60 {
+		t.Errorf("got offset %d, want %d", offset, 7*60*60)
+	}
+	if err := json.Unmarshal([]byte(`"yesterday"`), &got); err == nil {
+		t.Error("got nil error, want invalid date time")
+	}
+}
+
+func TestDate(t *testing.T) {
+	var got scalars.Date
+	if err := json.Unmarshal([]byte(`"2022-03-04"`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := scalars.NewDate(2022, time.March, 4); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the date doesn't shift in time zones behind UTC
+	loc := time.FixedZone("UTC-10", -10*60*60)
+	if in := got.In(loc); scalars.DateOf(in) != got {
+		t.Errorf("got %v, want %v", scalars.DateOf(in), got)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"2022-03-04"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	if err := json.Unmarshal([]byte(`"2022-02-30"`), &got); err == nil {
+		t.Error("got nil error, want invalid date")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var got struct {
+		Data  scalars.JSON
+		Empty scalars.JSON
+	}
+	if err := json.Unmarshal([]byte(`{"data": {"a": [1, 2]}, "empty": null}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a": [1, 2]}`; got.Data.String() != want {
+		t.Errorf("got %s, want %s", got.Data, want)
+	}
+	if !got.Empty.IsNull() {
+		t.Errorf("got %s, want null", got.Empty)
+	}
+	var data struct{ A []int }
+	if err := got.Data.Unmarshal(&data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.A) != 2 {
+		t.Errorf("got %v, want [1 2]", data.A)
+	}
+
+	b, err := json.Marshal(struct{ V, Zero scalars.JSON }{V: got.Data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"V":{"a":[1,2]},"Zero":null}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	const big = "123456789012345678901234567890"
+	for _, in := range []string{big, `"` + big + `"`} {
+		var got scalars.BigInt
+		if err := json.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got.String() != big {
+			t.Errorf("%s: got %s, want %s", in, got, big)
+		}
+		b, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != big {
+			t.Errorf("%s: got %s, want %s", in, b, big)
+		}
+	}
+
+	var got scalars.BigInt
+	if err := json.Unmarshal([]byte(`1.5`), &got); err == nil {
+		t.Error("got nil error, want invalid big integer")
+	}
+	if got.String() != "0" {
+		t.Errorf("got %s, want 0", got)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	const exact = "12345678901234567890.123456789012345678"
+	var got scalars.Decimal
+	if err := json.Unmarshal([]byte(exact), &got); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != exact {
+		t.Errorf("got %s, want %s", b, exact)
+	}
+	want, _ := new(big.Rat).SetString(exact)
+	if got.Rat().Cmp(want) != 0 {
+		t.Errorf("got %v, want %v", got.Rat(), want)
+	}
+
+	scalars.SetStringifyNumbers(true)
+	defer scalars.SetStringifyNumbers(false)
+	b, err = json.Marshal(scalars.NewDecimalFromRat(big.NewRat(1, 3), 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"0.3333"`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	for _, in := range []string{`"1.2.3"`, `"01"`, `"NaN"`} {
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("%s: got nil error, want invalid decimal", in)
+		}
+	}
+}
+
+func TestUUID(t *testing.T) {
+	const want = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	var got scalars.UUID
+	if err := json.Unmarshal([]byte(`"F47AC10B-58CC-4372-A567-0E02B2C3D479"`), &got); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"`+want+`"` {
+		t.Errorf("got %s, want %q", b, want)
+	}
+	if err := json.Unmarshal([]byte(`"f47ac10b"`), &got); err == nil {
+		t.Error("got nil error, want invalid UUID")
+	}
+	if scalars.NewUUID() == scalars.NewUUID() {
+		t.Error("got equal random UUIDs")
+	}
+}
+
+func TestSetTypeNames(t *testing.T) {
+	variables := map[string]interface{}{
+		"at":      scalars.DateTime{},
+		"day":     (*scalars.Date)(nil),
+		"data":    scalars.JSON(nil),
+		"count":   scalars.BigInt{},
+		"id":      scalars.UUID{},
+		"balance": scalars.Decimal{},
+	}
+	var q struct {
+		Accounts []struct {
+			ID        scalars.UUID
+			Balance   scalars.Decimal
+			UpdatedAt scalars.DateTime
+		} `graphql:"accounts(id: $id)"`
+	}
+
+	got, err := graphql.ConstructQuery(&q, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `query ($at:DateTime!$balance:Decimal!$count:BigInt!$data:JSON!$day:Date$id:UUID!){accounts(id: $id){id,balance,updatedAt}}`
+	if got != want {
+		t.Errorf("got:  %s\nwant: %s", got, want)
+	}
+
+	scalars.SetTypeNames(scalars.HasuraTypeNames)
+	defer scalars.SetTypeNames(scalars.DefaultTypeNames)
+	got, err = graphql.ConstructQuery(&q, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `query ($at:timestamptz!$balance:numeric!$count:bigint!$data:jsonb!$day:date$id:uuid!){accounts(id: $id){id,balance,updatedAt}}`
+	if got != want {
+		t.Errorf("got:  %s\nwant: %s", got, want)
+	}
+
+	var result struct {
+		Accounts []struct {
+			ID        scalars.UUID
+			Balance   scalars.Decimal
+			UpdatedAt scalars.DateTime
+		}
+	}
+	err = graphql.UnmarshalGraphQL([]byte(`{"accounts": [{"id": "f47ac10b-58cc-4372-a567-0e02b2c3d479", "balance": 10.50, "updatedAt": "2022-03-04T05:06:07+02:00"}]}`), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Accounts) != 1 || result.Accounts[0].Balance.String() != "10.50" || result.Accounts[0].UpdatedAt.String() != "2022-03-04T05:06:07+02:00" {
+		t.Errorf("got %+v", result.Accounts)
+	}
+}
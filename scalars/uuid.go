@@ -0,0 +1,62 @@
+package scalars
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// UUID is a universally unique identifier, encoded as "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx"
+type UUID [16]byte
+
+// NewUUID returns a random UUID of version 4
+func NewUUID() UUID {
+	return UUID(uuid.New())
+}
+
+// ParseUUID parses the UUID string s. The formats of uuid.Parse are accepted, e.g. with braces or the urn:uuid: prefix
+func ParseUUID(s string) (UUID, error) {
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return UUID{}, fmt.Errorf("invalid UUID %q: %w", s, err)
+	}
+	return UUID(u), nil
+}
+
+// GetGraphQLType returns the GraphQL type name of UUID, see SetTypeNames
+func (UUID) GetGraphQLType() string {
+	return GetTypeNames().UUID
+}
+
+// IsZero reports whether the UUID is the nil UUID
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// String returns the UUID in the canonical lowercase format
+func (u UUID) String() string {
+	return uuid.UUID(u).String()
+}
+
+// MarshalJSON encodes the UUID as a string
+func (u UUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.String())
+}
+
+// UnmarshalJSON decodes the UUID string. Null is a no-op
+func (u *UUID) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseUUID(s)
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
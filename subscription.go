@@ -1,12 +1,14 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,6 +16,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
 	"nhooyr.io/websocket"
 	"nhooyr.io/websocket/wsjson"
 )
@@ -36,6 +40,8 @@ const (
 	SubscriptionRunning SubscriptionStatus = 1
 	// SubscriptionUnsubcribed the subscription was manually unsubscribed by the user
 	SubscriptionUnsubcribed SubscriptionStatus = 2
+	// SubscriptionCompleted the subscription was completed by the server or closed with the client
+	SubscriptionCompleted SubscriptionStatus = 3
 
 	// SubscriptionsTransportWS the enum implements the subscription transport that follows Apollo's subscriptions-transport-ws protocol specification
 	// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
@@ -78,10 +84,95 @@ var (
 	ErrSubscriptionStopped = errors.New("subscription stopped")
 	// ErrSubscriptionNotExists an error denoting that subscription does not exist
 	ErrSubscriptionNotExists = errors.New("subscription does not exist")
-
-	errRetry = errors.New("retry subscription client")
+	// ErrSubscriptionCompleted the subscription was completed by the server
+	ErrSubscriptionCompleted = errors.New("subscription completed")
+	// ErrSubscriptionUnsubscribed the subscription was unsubscribed by the user
+	ErrSubscriptionUnsubscribed = errors.New("subscription unsubscribed")
+	// ErrSubscriptionClientClosed the subscription ended because the client was closed
+	ErrSubscriptionClientClosed = errors.New("subscription client closed")
+	// ErrKeepAliveTimeout the connection is considered dead because the server didn't send any message in time,
+	// see WithIdleTimeout and WithPing
+	ErrKeepAliveTimeout = errors.New("keepalive timeout")
+	// ErrConnectionAckTimeout the server didn't acknowledge the connection in time, see WithConnectionAckTimeout
+	ErrConnectionAckTimeout = errors.New("connection acknowledgement timeout")
+
+	errRetry = errors.New("connection lost")
 )
 
+// retryError is the cause of reconnecting. It matches errRetry and unwraps to the error of the connection
+type retryError struct {
+	err error
+}
+
+func newRetryError(err error) error {
+	return &retryError{err: err}
+}
+
+// Error implements error interface.
+func (e *retryError) Error() string {
+	return fmt.Sprintf("%s: %s", errRetry, e.err)
+}
+
+// Unwrap returns the underlying error
+func (e *retryError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error matches target, for errors.Is(err, errRetry)
+func (e *retryError) Is(target error) bool {
+	return target == errRetry
+}
+
+// WebsocketCloseError is the error of the websocket connection closed by the server,
+// e.g. with 4401 Unauthorized or 4408 Connection initialisation timeout of the graphql-ws protocol
+type WebsocketCloseError struct {
+	// Code is the close status code
+	Code websocket.StatusCode
+	// Reason is the close reason sent by the server
+	Reason string
+	Err    error
+}
+
+func newWebsocketCloseError(code int32, err error) *WebsocketCloseError {
+	reason := err.Error()
+	var closeErr websocket.CloseError
+	if errors.As(err, &closeErr) {
+		reason = closeErr.Reason
+	}
+	return &WebsocketCloseError{
+		Code:   websocket.StatusCode(code),
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+// Error implements error interface.
+func (e *WebsocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d: %s", e.Code, e.Reason)
+}
+
+// Unwrap returns the underlying error
+func (e *WebsocketCloseError) Unwrap() error {
+	return e.Err
+}
+
+// SubscriptionDecodeError is passed to typed subscription handlers when the data of a message can't be decoded into the query struct
+type SubscriptionDecodeError struct {
+	// Data is the raw data of the message
+	Data []byte
+	Err  error
+}
+
+// Error implements error interface.
+func (e *SubscriptionDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode subscription data: %v", e.Err)
+}
+
+// Unwrap returns the underlying error, usually *DecodeError
+func (e *SubscriptionDecodeError) Unwrap() error {
+	return e.Err
+}
+
 // OperationMessage represents a subscription operation message
 type OperationMessage struct {
 	ID      string               `json:"id,omitempty"`
@@ -138,6 +229,7 @@ type SubscriptionContext struct {
 	OnConnected            func()
 	OnDisconnected         func()
 	OnConnectionAlive      func()
+	OnConnectionAck        func(payload []byte)
 	OnSubscriptionComplete func(sub Subscription)
 
 	cancel           context.CancelFunc
@@ -145,6 +237,15 @@ type SubscriptionContext struct {
 	disabledLogTypes []OperationMessageType
 	log              func(args ...interface{})
 	acknowledged     int32
+	// startedChan is closed when the session is started, see started
+	startedChan chan struct{}
+	isStarted   int32
+	// lastKeepAliveAt is the unix time in nanoseconds of the last keepalive message from the server: ka, ping or pong
+	lastKeepAliveAt int64
+	// lastPongAt is the unix time in nanoseconds of the last pong message from the server
+	lastPongAt int64
+	// readerBlocked is 1 while the reader waits for room in a delivery queue, see DeliveryBlock
+	readerBlocked    int32
 	retryStatusCodes [][]int32
 	mutex            sync.Mutex
 }
@@ -279,6 +380,85 @@ func (sc *SubscriptionContext) SetAcknowledge(value bool) {
 	}
 }
 
+// started returns the channel that is closed when the connection is acknowledged and the subscriptions are started
+func (sc *SubscriptionContext) started() <-chan struct{} {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if sc.startedChan == nil {
+		sc.startedChan = make(chan struct{})
+	}
+	return sc.startedChan
+}
+
+// setStarted closes the channel of started once
+func (sc *SubscriptionContext) setStarted() {
+	if !atomic.CompareAndSwapInt32(&sc.isStarted, 0, 1) {
+		return
+	}
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	if sc.startedChan == nil {
+		sc.startedChan = make(chan struct{})
+	}
+	close(sc.startedChan)
+}
+
+// resetKeepAlive starts the keepalive checks of the session from now
+func (sc *SubscriptionContext) resetKeepAlive() {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&sc.lastKeepAliveAt, now)
+	atomic.StoreInt64(&sc.lastPongAt, now)
+}
+
+// receive records the keepalive messages from the server. Data and other messages are ignored,
+// because a server may keep sending them while its keepalive is broken
+func (sc *SubscriptionContext) receive(messageType OperationMessageType) {
+	switch messageType {
+	case GQLPong:
+		atomic.StoreInt64(&sc.lastPongAt, time.Now().UnixNano())
+		fallthrough
+	case GQLConnectionKeepAlive, GQLPing:
+		atomic.StoreInt64(&sc.lastKeepAliveAt, time.Now().UnixNano())
+	}
+}
+
+// setReaderBlocked marks that the reader waits for room in a delivery queue, so it can't read keepalive messages.
+// The keepalive checks start from now when the reader continues, so the blocked time isn't counted against the connection
+func (sc *SubscriptionContext) setReaderBlocked(blocked bool) {
+	if blocked {
+		atomic.StoreInt32(&sc.readerBlocked, 1)
+		return
+	}
+	sc.resetKeepAlive()
+	atomic.StoreInt32(&sc.readerBlocked, 0)
+}
+
+// isReaderBlocked reports whether the reader waits for room in a delivery queue
+func (sc *SubscriptionContext) isReaderBlocked() bool {
+	return atomic.LoadInt32(&sc.readerBlocked) == 1
+}
+
+// lastKeepAlive returns the time of the last keepalive message from the server
+func (sc *SubscriptionContext) lastKeepAlive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&sc.lastKeepAliveAt))
+}
+
+// lastPong returns the time of the last pong message from the server
+func (sc *SubscriptionContext) lastPong() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&sc.lastPongAt))
+}
+
+// terminate closes the session and its connection when the session is replaced, without triggering OnDisconnected.
+// The session is cancelled first, so the errors of closing the connection aren't reported
+func (sc *SubscriptionContext) terminate() {
+	conn := sc.GetWebsocketConn()
+	sc.SetWebsocketConn(nil)
+	sc.Cancel()
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
 // Close closes the context and the inner websocket connection if exists
 func (sc *SubscriptionContext) Close() error {
 	var err error
@@ -313,6 +493,7 @@ type Subscription struct {
 	payload GraphQLRequestPayload
 	handler func(data []byte, err error)
 	status  SubscriptionStatus
+	handle  *SubscriptionHandle
 }
 
 // GetID returns the subscription ID
@@ -356,13 +537,28 @@ type SubscriptionClient struct {
 	protocol               SubscriptionProtocol
 	websocketOptions       WebsocketOptions
 	timeout                time.Duration
+	readTimeout            time.Duration
+	idleTimeout            time.Duration
+	pingInterval           time.Duration
+	pingTimeout            time.Duration
+	ackTimeout             time.Duration
+	refreshInterval        time.Duration
+	refreshChan            chan struct{}
+	reauth                 func(ctx *SubscriptionContext, connectionParams map[string]interface{}) error
 	clientStatus           int32
 	readLimit              int64 // max size of response message. Default 10 MB
 	createConn             func(sc *SubscriptionClient) (WebsocketConn, error)
 	retryTimeout           time.Duration
 	onError                func(sc *SubscriptionClient, err error) error
-	errorChan              chan error
+	errorChan              chan sessionError
 	exitWhenNoSubscription bool
+	naming                 ident.NamingStrategy
+	deliveryQueueSize      int
+	deliveryPolicy         DeliveryPolicy
+	goroutines             goroutineGroup
+	backoff                BackoffPolicy
+	onReconnecting         func(attempt int, delay time.Duration, err error)
+	isRetriable            func(err error) bool
 	mutex                  sync.Mutex
 }
 
@@ -374,7 +570,9 @@ func NewSubscriptionClient(url string) *SubscriptionClient {
 		readLimit:              10 * 1024 * 1024, // set default limit 10MB
 		createConn:             newWebsocketConn,
 		retryTimeout:           time.Minute,
-		errorChan:              make(chan error),
+		backoff:                ConstantBackoff{Interval: time.Second},
+		errorChan:              make(chan sessionError),
+		refreshChan:            make(chan struct{}, 1),
 		protocol:               &subscriptionsTransportWS{},
 		exitWhenNoSubscription: true,
 		context: &SubscriptionContext{
@@ -440,13 +638,13 @@ func (sc *SubscriptionClient) WithConnectionParamsFn(fn func() map[string]interf
 	return sc
 }
 
-// WithTimeout updates read and write timeout of websocket client
+// WithTimeout updates write timeout of websocket client, which is the read timeout too unless WithReadTimeout is set
 func (sc *SubscriptionClient) WithTimeout(timeout time.Duration) *SubscriptionClient {
 	sc.timeout = timeout
 	return sc
 }
 
-// WithRetryTimeout updates reconnecting timeout. When the websocket server was stopped, the client will retry connecting every second until timeout
+// WithRetryTimeout updates reconnecting timeout. When the websocket server was stopped, the client will retry connecting until timeout, with the delays of the backoff policy, see WithBackoff
 // The zero value means unlimited timeout
 func (sc *SubscriptionClient) WithRetryTimeout(timeout time.Duration) *SubscriptionClient {
 	sc.retryTimeout = timeout
@@ -477,6 +675,16 @@ func (sc *SubscriptionClient) WithReadLimit(limit int64) *SubscriptionClient {
 	return sc
 }
 
+// WithDeliveryQueue sets the number of data messages that are queued per subscription while its handler is busy,
+// and the policy when the queue is full. The messages of each subscription are delivered to the handler one by one,
+// in the order they arrive. The default is a queue of 100 messages that blocks reading when it's full.
+// The settings apply to subscriptions that are subscribed afterwards
+func (sc *SubscriptionClient) WithDeliveryQueue(size int, policy DeliveryPolicy) *SubscriptionClient {
+	sc.deliveryQueueSize = size
+	sc.deliveryPolicy = policy
+	return sc
+}
+
 // WithRetryStatusCodes allow retry the subscription connection when receiving one of these codes
 // the input parameter can be number string or range, e.g 4000-5000
 func (sc *SubscriptionClient) WithRetryStatusCodes(codes ...string) *SubscriptionClient {
@@ -489,6 +697,37 @@ func (sc *SubscriptionClient) WithRetryStatusCodes(codes ...string) *Subscriptio
 	return sc
 }
 
+// WithNamingStrategy sets the naming strategy of struct fields without graphql tag in subscription queries.
+// Messages must be decoded with the same strategy, e.g. by UnmarshalGraphQL with the WithNamingStrategy option
+func (sc *SubscriptionClient) WithNamingStrategy(naming ident.NamingStrategy) *SubscriptionClient {
+	sc.naming = naming
+	return sc
+}
+
+// WithBackoff sets the policy of the delays between the connection attempts.
+// The client reconnects until the policy stops or the retry timeout is exceeded.
+// The default policy retries every second, see ExponentialBackoff to spread the reconnections of many clients
+func (sc *SubscriptionClient) WithBackoff(policy BackoffPolicy) *SubscriptionClient {
+	sc.backoff = policy
+	return sc
+}
+
+// WithRetryClassifier sets the function that reports whether a connection error is retriable.
+// It classifies the errors of connection attempts and the causes of reconnecting, e.g. ErrConnectionAckTimeout.
+// The client stops without retrying on non-retriable errors, e.g. a *WebsocketDialError with 401 status code.
+// All errors are retriable by default
+func (sc *SubscriptionClient) WithRetryClassifier(isRetriable func(err error) bool) *SubscriptionClient {
+	sc.isRetriable = isRetriable
+	return sc
+}
+
+// OnReconnecting event is triggered before waiting for the delay of a connection attempt,
+// with the number of the attempt, starting at 1, the delay and the error that caused the attempt
+func (sc *SubscriptionClient) OnReconnecting(fn func(attempt int, delay time.Duration, err error)) *SubscriptionClient {
+	sc.onReconnecting = fn
+	return sc
+}
+
 // OnError event is triggered when there is any connection error. This is bottom exception handler level
 // If this function is empty, or returns nil, the client restarts the connection
 // If returns error, the websocket connection will be terminated
@@ -515,6 +754,13 @@ func (sc *SubscriptionClient) OnConnectionAlive(fn func()) *SubscriptionClient {
 	return sc
 }
 
+// OnConnectionAck event is triggered when the server acknowledges the connection, with the payload of the ack message,
+// before the subscriptions are started. The payload is empty if the server doesn't send any
+func (sc *SubscriptionClient) OnConnectionAck(fn func(payload []byte)) *SubscriptionClient {
+	sc.context.OnConnectionAck = fn
+	return sc
+}
+
 // OnSubscriptionComplete event is triggered when the subscription receives a terminated message from the server
 func (sc *SubscriptionClient) OnSubscriptionComplete(fn func(sub Subscription)) *SubscriptionClient {
 	sc.context.OnSubscriptionComplete = fn
@@ -543,94 +789,175 @@ func (sc *SubscriptionClient) setClientStatus(value int32) {
 	atomic.StoreInt32(&sc.clientStatus, value)
 }
 
-// initializes the websocket connection
-func (sc *SubscriptionClient) init() error {
+// initializes the websocket connection.
+// If cause isn't nil, the client reconnects after losing the connection because of cause,
+// so it waits for the backoff delay before the first attempt too
+func (sc *SubscriptionClient) init(runCtx context.Context, cause error) error {
 
 	now := time.Now()
 	ctx := sc.getContext()
+	attempt := 0
 	for {
-		var err error
-		var conn WebsocketConn
-		// allow custom websocket client
-		if ctx.GetWebsocketConn() == nil {
-			ctx.NewContext()
-			conn, err = sc.createConn(sc)
-			if err == nil {
-				ctx.SetWebsocketConn(conn)
+		if cause != nil {
+			attempt++
+			delay, ok := sc.backoff.NextDelay(attempt)
+			if !ok {
+				sc.disconnected(ctx)
+				return fmt.Errorf("max reconnection attempts exceeded: %w", cause)
 			}
-		}
-
-		if err == nil {
-			ctx.GetWebsocketConn().SetReadLimit(sc.readLimit)
-			// send connection init event to the server
-			connectionParams := sc.connectionParams
-			if sc.connectionParamsFn != nil {
-				connectionParams = sc.connectionParamsFn()
+			if sc.retryTimeout > 0 && now.Add(sc.retryTimeout).Before(time.Now()) {
+				sc.disconnected(ctx)
+				return fmt.Errorf("retry timeout: %w", cause)
+			}
+			if sc.onReconnecting != nil {
+				sc.onReconnecting(attempt, delay, cause)
+			}
+			ctx.Log(fmt.Sprintf("%s. retry in %s...", cause.Error(), delay), "client", GQLInternal)
+			select {
+			case <-runCtx.Done():
+				return runCtx.Err()
+			case <-time.After(delay):
 			}
-			err = sc.protocol.ConnectionInit(ctx, connectionParams)
 		}
 
+		err := sc.dial(ctx)
 		if err == nil {
 			return nil
 		}
+		if sc.isRetriable != nil && !sc.isRetriable(err) {
+			sc.disconnected(ctx)
+			return fmt.Errorf("non-retriable connection error: %w", err)
+		}
+		cause = err
+	}
+}
 
-		if sc.retryTimeout > 0 && now.Add(sc.retryTimeout).Before(time.Now()) {
-			if ctx.OnDisconnected != nil {
-				ctx.OnDisconnected()
-			}
+// dial creates the websocket connection of ctx, which must be the current context of the client, if it doesn't exist,
+// and sends the connection init message
+func (sc *SubscriptionClient) dial(ctx *SubscriptionContext) error {
+	// allow custom websocket client
+	if ctx.GetWebsocketConn() == nil {
+		ctx.NewContext()
+		conn, err := sc.createConn(sc)
+		if err != nil {
 			return err
 		}
-		ctx.Log(fmt.Sprintf("%s. retry in second...", err.Error()), "client", GQLInternal)
-		time.Sleep(time.Second)
+		ctx.SetWebsocketConn(conn)
+	}
+
+	ctx.GetWebsocketConn().SetReadLimit(sc.readLimit)
+	// send connection init event to the server
+	return sc.protocol.ConnectionInit(ctx, sc.getConnectionParams())
+}
+
+// getConnectionParams returns the connection params, which are refreshed by the function of WithConnectionParamsFn
+func (sc *SubscriptionClient) getConnectionParams() map[string]interface{} {
+	if sc.connectionParamsFn != nil {
+		return sc.connectionParamsFn()
+	}
+	return sc.connectionParams
+}
+
+// disconnected triggers the OnDisconnected event when the client gives up connecting
+func (sc *SubscriptionClient) disconnected(ctx *SubscriptionContext) {
+	if ctx.OnDisconnected != nil {
+		ctx.OnDisconnected()
 	}
 }
 
 // Subscribe sends start message to server and open a channel to receive data.
 // The handler callback function will receive raw message data or error. If the call return error, onError event will be triggered
-// The function returns subscription ID and error. You can use subscription ID to unsubscribe the subscription
-func (sc *SubscriptionClient) Subscribe(v interface{}, variables map[string]interface{}, handler func(message []byte, err error) error, options ...Option) (string, error) {
+// The function returns the handle of the subscription, which keeps its identity across reconnects, and error.
+// You can use the handle to unsubscribe, wait for or resubscribe the subscription
+func (sc *SubscriptionClient) Subscribe(v interface{}, variables interface{}, handler func(message []byte, err error) error, options ...Option) (*SubscriptionHandle, error) {
 	return sc.do(v, variables, handler, options...)
 }
 
+// SubscribeTyped sends start message to server and open a channel to receive data, like Subscribe.
+// The data of each message is decoded into a new value of the type that v points to,
+// which is passed to the handler, so handlers don't share the query struct.
+// If the data can't be decoded, the handler receives nil data and a *SubscriptionDecodeError
+func (sc *SubscriptionClient) SubscribeTyped(v interface{}, variables interface{}, handler func(data interface{}, err error) error, options ...Option) (*SubscriptionHandle, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("the query of a typed subscription must be a pointer, got %T", v)
+	}
+	return sc.do(v, variables, sc.typedHandler(t.Elem(), handler), options...)
+}
+
+// typedHandler returns the raw handler that decodes the data into a new value of type t for the typed handler.
+// Null data is passed as the zero value
+func (sc *SubscriptionClient) typedHandler(t reflect.Type, handler func(data interface{}, err error) error) handlerFunc {
+	options := []jsonutil.DecoderOption{jsonutil.WithNamingStrategy(sc.naming)}
+	return func(data []byte, err error) error {
+		if err != nil {
+			return handler(nil, err)
+		}
+		out := reflect.New(t)
+		if len(data) > 0 && !bytes.Equal(data, []byte("null")) {
+			if err := jsonutil.UnmarshalGraphQL(data, out.Interface(), options...); err != nil {
+				return handler(nil, &SubscriptionDecodeError{Data: data, Err: err})
+			}
+		}
+		return handler(out.Interface(), nil)
+	}
+}
+
 // NamedSubscribe sends start message to server and open a channel to receive data, with operation name
 //
 // Deprecated: this is the shortcut of Subscribe method, with NewOperationName option
-func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables map[string]interface{}, handler func(message []byte, err error) error, options ...Option) (string, error) {
+func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables interface{}, handler func(message []byte, err error) error, options ...Option) (*SubscriptionHandle, error) {
 	return sc.do(v, variables, handler, append(options, OperationName(name))...)
 }
 
 // SubscribeRaw sends start message to server and open a channel to receive data, with raw query
 // Deprecated: use Exec instead
-func (sc *SubscriptionClient) SubscribeRaw(query string, variables map[string]interface{}, handler func(message []byte, err error) error) (string, error) {
-	return sc.doRaw(query, variables, "", handler)
-}
-
-// Exec sends start message to server and open a channel to receive data, with raw query
-func (sc *SubscriptionClient) Exec(query string, variables map[string]interface{}, handler func(message []byte, err error) error) (string, error) {
-	return sc.doRaw(query, variables, "", handler)
+func (sc *SubscriptionClient) SubscribeRaw(query string, variables interface{}, handler func(message []byte, err error) error) (*SubscriptionHandle, error) {
+	return sc.Exec(query, variables, handler)
 }
 
-func (sc *SubscriptionClient) do(v interface{}, variables map[string]interface{}, handler func(message []byte, err error) error, options ...Option) (string, error) {
-	query, operationName, err := ConstructSubscription(v, variables, options...)
+// Exec sends start message to server and open a channel to receive data, with raw query.
+// The query is parsed before subscribing, so malformed documents are rejected immediately.
+// The operation name is filled from the OperationName option, or from the document if it contains a single named operation.
+func (sc *SubscriptionClient) Exec(query string, variables interface{}, handler func(message []byte, err error) error, options ...Option) (*SubscriptionHandle, error) {
+	operationName, err := parseExecOperationName(query, true, options)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return sc.subscribe(variables, func(map[string]interface{}) (string, string, error) {
+		return query, operationName, nil
+	}, handler)
+}
 
-	return sc.doRaw(query, variables, operationName, handler)
+func (sc *SubscriptionClient) do(v interface{}, variables interface{}, handler func(message []byte, err error) error, options ...Option) (*SubscriptionHandle, error) {
+	return sc.subscribe(variables, func(vars map[string]interface{}) (string, string, error) {
+		return constructOperation(subscriptionOperation, v, vars, options, sc.naming)
+	}, handler)
 }
 
-func (sc *SubscriptionClient) doRaw(query string, variables map[string]interface{}, operationName string, handler func(message []byte, err error) error) (string, error) {
-	id := uuid.New().String()
+// subscribe registers the subscription of the query that build returns for the variables,
+// and starts it immediately if the client is running
+func (sc *SubscriptionClient) subscribe(variables interface{}, build buildSubscription, handler func(message []byte, err error) error) (*SubscriptionHandle, error) {
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return nil, err
+	}
+	query, operationName, err := build(vars)
+	if err != nil {
+		return nil, err
+	}
 
+	handle := newSubscriptionHandle(sc, build)
 	sub := Subscription{
-		id:  id,
-		key: id,
+		id:  uuid.New().String(),
+		key: handle.key,
 		payload: GraphQLRequestPayload{
 			Query:         query,
-			Variables:     variables,
+			Variables:     encodeVariables(vars),
 			OperationName: operationName,
 		},
-		handler: sc.wrapHandler(handler),
+		handler: sc.wrapHandler(handle, handler),
+		handle:  handle,
 	}
 
 	// if the websocket client is running and acknowledged by the server
@@ -638,33 +965,82 @@ func (sc *SubscriptionClient) doRaw(query string, variables map[string]interface
 	ctx := sc.getContext()
 	if ctx != nil && sc.getClientStatus() == scStatusRunning && ctx.GetAcknowledge() {
 		if err := sc.protocol.Subscribe(ctx, sub); err != nil {
-			return "", err
+			return nil, err
 		}
 	} else {
-		ctx.SetSubscription(id, &sub)
+		ctx.SetSubscription(sub.key, &sub)
+	}
+
+	return handle, nil
+}
+
+// deliver passes the message to the protocol, which calls the handler of the subscription.
+// Messages are discarded after the client is closed
+func (sc *SubscriptionClient) deliver(item queuedMessage) {
+	if sc.getClientStatus() == scStatusClosing {
+		return
+	}
+	if err := sc.protocol.OnMessage(item.ctx, item.sub, item.message); err != nil {
+		sc.sendError(item.ctx, err)
+	}
+	// the protocol starts the subscriptions when it handles the ack message
+	if item.ctx.GetAcknowledge() {
+		item.ctx.setStarted()
 	}
 
-	return id, nil
+	sc.checkSubscriptionStatuses(item.ctx)
 }
 
-func (sc *SubscriptionClient) wrapHandler(fn handlerFunc) func(data []byte, err error) {
+func (sc *SubscriptionClient) wrapHandler(handle *SubscriptionHandle, fn handlerFunc) func(data []byte, err error) {
 	return func(data []byte, err error) {
+		handle.record(err)
 		if errValue := fn(data, err); errValue != nil {
-			sc.errorChan <- errValue
+			sc.sendError(sc.getContext(), errValue)
 		}
 	}
 }
 
+// resubscribe replaces the payload of the subscription of key. A running subscription is stopped
+// and started again with a new id, so messages of the previous payload aren't delivered
+func (sc *SubscriptionClient) resubscribe(key string, payload GraphQLRequestPayload) error {
+	ctx := sc.getContext()
+	if ctx == nil {
+		return fmt.Errorf("%s, %w", key, ErrSubscriptionNotExists)
+	}
+	sub := ctx.GetSubscription(key)
+	if sub == nil || sub.status == SubscriptionUnsubcribed {
+		return fmt.Errorf("%s, %w", key, ErrSubscriptionNotExists)
+	}
+
+	running := sub.status == SubscriptionRunning
+	if running {
+		if err := sc.protocol.Unsubscribe(ctx, *sub); err != nil {
+			return err
+		}
+		sub.id = uuid.NewString()
+		sub.status = SubscriptionWaiting
+	}
+	sub.payload = payload
+	ctx.SetSubscription(sub.key, sub)
+	if running {
+		return sc.protocol.Subscribe(ctx, *sub)
+	}
+	return nil
+}
+
 // Unsubscribe sends stop message to server and close subscription channel
 // The input parameter is subscription ID that is returned from Subscribe function
 func (sc *SubscriptionClient) Unsubscribe(id string) error {
 	ctx := sc.getContext()
-	if ctx == nil || ctx.GetWebsocketConn() == nil {
+	if ctx == nil {
 		return nil
 	}
 	sub := ctx.GetSubscription(id)
 
 	if sub == nil {
+		if ctx.GetWebsocketConn() == nil {
+			return nil
+		}
 		return fmt.Errorf("%s, %w", id, ErrSubscriptionNotExists)
 	}
 
@@ -677,6 +1053,7 @@ func (sc *SubscriptionClient) Unsubscribe(id string) error {
 	}
 	sub.status = SubscriptionUnsubcribed
 	ctx.SetSubscription(sub.key, sub)
+	sub.handle.finish(ErrSubscriptionUnsubscribed)
 
 	sc.checkSubscriptionStatuses(ctx)
 
@@ -685,15 +1062,71 @@ func (sc *SubscriptionClient) Unsubscribe(id string) error {
 
 // Run start the WebSocket client and subscriptions.
 // If the client is running, recalling this function will restart all registered subscriptions
-// If this function is run with goroutine, it can be stopped after closed
+// If this function is run with goroutine, it can be stopped after closed.
+// It's the shortcut of RunContext with the background context
 func (sc *SubscriptionClient) Run() error {
+	return sc.RunContext(context.Background())
+}
+
+// the states of the supervisor loop of RunContext
+type runState int
+
+const (
+	// runConnecting the client connects to the server and starts the reader of the connection
+	runConnecting runState = iota
+	// runRunning the client waits for the events of the connection
+	runRunning
+	// runReconnecting the client closes the connection and resets the subscriptions to connect again
+	runReconnecting
+	// runStopped the client is stopped, waiting for its goroutines to exit
+	runStopped
+)
 
+// RunContext starts the WebSocket client and subscriptions, and supervises the connection until the client stops.
+// The client reconnects when the connection is lost or onError recovers from an error, without growing the stack.
+// Cancelling ctx unsubscribes all subscriptions and closes the connection gracefully.
+//
+// It returns after the reader and handler goroutines exit, with nil if the client is closed by Close,
+// ErrSubscriptionStopped or when there's no subscription, ctx.Err() if ctx is cancelled,
+// the error of onError, the *WebsocketCloseError of the server if there's no onError callback,
+// or the connection error if the retry timeout is exceeded or the error isn't retriable
+func (sc *SubscriptionClient) RunContext(ctx context.Context) error {
+	state := runConnecting
 	if sc.getClientStatus() != scStatusInitializing {
-		sc.reset()
+		state = runReconnecting
+	}
+
+	// result is the terminal error of the client, or the cause of reconnecting
+	var result error
+	for {
+		switch state {
+		case runReconnecting:
+			sc.reset()
+			state = runConnecting
+		case runConnecting:
+			if result = sc.connect(ctx, result); result != nil {
+				state = runStopped
+			} else {
+				state = runRunning
+			}
+		case runRunning:
+			state, result = sc.supervise(ctx)
+		default:
+			sc.waitGoroutines()
+			return result
+		}
 	}
+}
 
-	if err := sc.init(); err != nil {
-		return fmt.Errorf("retry timeout. exiting...")
+// connect initializes the websocket connection and starts its reader.
+// cause is the error that the connection was lost because of, nil for the first connection
+func (sc *SubscriptionClient) connect(ctx context.Context, cause error) error {
+	if err := sc.init(ctx, cause); err != nil {
+		if ctx.Err() != nil {
+			sc.close(sc.getContext())
+			return ctx.Err()
+		}
+		return err
 	}
 
 	subContext := sc.getContext()
@@ -707,111 +1140,332 @@ func (sc *SubscriptionClient) Run() error {
 	}
 
 	sc.setClientStatus(scStatusRunning)
-	ctx := subContext.GetContext()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				var message OperationMessage
-				if err := conn.ReadJSON(&message); err != nil {
-					// manual EOF check
-					if err == io.EOF || strings.Contains(err.Error(), "EOF") || strings.Contains(err.Error(), "connection reset by peer") {
-						sc.errorChan <- errRetry
-						return
-					}
-					if errors.Is(err, context.Canceled) {
-						return
-					}
-
-					closeStatus := conn.GetCloseStatus(err)
-
-					for _, retryCode := range subContext.retryStatusCodes {
-						if (len(retryCode) == 1 && retryCode[0] == closeStatus) ||
-							(len(retryCode) >= 2 && retryCode[0] <= closeStatus && closeStatus <= retryCode[1]) {
-							sc.errorChan <- errRetry
-							return
-						}
-					}
-
-					switch websocket.StatusCode(closeStatus) {
-					case websocket.StatusBadGateway, websocket.StatusNoStatusRcvd:
-						sc.errorChan <- errRetry
-						return
-					case websocket.StatusNormalClosure, websocket.StatusAbnormalClosure:
-						// close event from websocket client, exiting...
-						subContext.Cancel()
-						return
-					case StatusInvalidMessage, StatusConnectionInitialisationTimeout, StatusTooManyInitialisationRequests, StatusSubscriberAlreadyExists, StatusUnauthorized:
-						subContext.Log(err, "server", GQL_CONNECTION_ERROR)
-						sc.errorChan <- err
-						return
-					}
+	sc.startSession(subContext, conn)
+	if sc.ackTimeout > 0 {
+		sc.goroutines.Go(func() {
+			sc.waitConnectionAck(subContext)
+		})
+	}
+	return nil
+}
 
-					if sc.onError != nil {
-						if err = sc.onError(sc, err); err != nil {
-							// end the subscription if the callback return error
-							subContext.Cancel()
-							return
-						}
-					}
-					continue
-				}
+// startSession starts the reader and the keepalive checks of the connection of the session
+func (sc *SubscriptionClient) startSession(subContext *SubscriptionContext, conn WebsocketConn) {
+	subContext.resetKeepAlive()
+	sc.goroutines.Go(func() {
+		sc.read(subContext, conn)
+	})
+	sc.goroutines.Go(func() {
+		sc.keepAlive(subContext)
+	})
+}
 
-				sub := subContext.GetSubscription(message.ID)
-				if sub == nil {
-					sub = &Subscription{}
-				}
-				go func() {
-					if err := sc.protocol.OnMessage(subContext, *sub, message); err != nil {
-						sc.errorChan <- err
-					}
+// supervise waits for the events of the running connection and returns the next state of the client,
+// with the terminal error of the client or the cause of reconnecting
+func (sc *SubscriptionClient) supervise(ctx context.Context) (runState, error) {
+	subContext := sc.getContext()
+	sessionCtx := subContext.GetContext()
+
+	var refreshTimer *time.Timer
+	var refreshTimeout <-chan time.Time
+	if sc.refreshInterval > 0 {
+		refreshTimer = time.NewTimer(sc.refreshInterval)
+		defer refreshTimer.Stop()
+		refreshTimeout = refreshTimer.C
+	}
 
-					sc.checkSubscriptionStatuses(subContext)
-				}()
-			}
+	// the refresh runs in its own goroutine, so errors, the cancellation of ctx and Close are handled
+	// while the new connection is acknowledged. The timer is stopped while refreshing
+	refreshResult := make(chan *SubscriptionContext, 1)
+	var cancelRefresh context.CancelFunc
+	startRefresh := func() {
+		var refreshCtx context.Context
+		refreshCtx, cancelRefresh = context.WithCancel(ctx)
+		current := subContext
+		sc.goroutines.Go(func() {
+			refreshResult <- sc.refresh(refreshCtx, current)
+		})
+	}
+	// finishRefresh cancels the running refresh, if any, and waits for the context of the running session
+	finishRefresh := func() {
+		if cancelRefresh == nil {
+			return
 		}
-	}()
+		cancelRefresh()
+		cancelRefresh = nil
+		subContext = <-refreshResult
+		sessionCtx = subContext.GetContext()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return sc.close(subContext)
-		case e := <-sc.errorChan:
+			finishRefresh()
+			sc.close(subContext)
+			return runStopped, ctx.Err()
+		case <-sessionCtx.Done():
+			current := subContext
+			finishRefresh()
+			if subContext != current {
+				// the session is replaced by the refresh
+				continue
+			}
+			return runStopped, sc.close(subContext)
+		case <-refreshTimeout:
+			if cancelRefresh == nil {
+				startRefresh()
+			}
+		case <-sc.refreshChan:
+			if cancelRefresh == nil {
+				if refreshTimer != nil && !refreshTimer.Stop() {
+					<-refreshTimer.C
+				}
+				startRefresh()
+			}
+		case next := <-refreshResult:
+			cancelRefresh()
+			cancelRefresh = nil
+			subContext = next
+			sessionCtx = subContext.GetContext()
+			if refreshTimer != nil {
+				refreshTimer.Reset(sc.refreshInterval)
+			}
+		case se := <-sc.errorChan:
+			if cancelRefresh != nil {
+				if se.ctx != subContext && errors.Is(se.err, errRetry) {
+					// the new connection of the refresh failed, so the subscriptions keep running on the current connection
+					cancelRefresh()
+					continue
+				}
+				finishRefresh()
+				if se.ctx != subContext && errors.Is(se.err, errRetry) {
+					// the connection failed after it's replaced by the refresh
+					continue
+				}
+			}
+			e := se.err
 			if sc.getClientStatus() == scStatusClosing {
-				return nil
+				return runStopped, nil
 			}
 
 			// stop the subscription if the error has stop message
 			if e == ErrSubscriptionStopped {
-				return sc.close(subContext)
+				return runStopped, sc.close(subContext)
 			}
-			if e == errRetry {
-				return sc.Run()
+			if errors.Is(e, errRetry) {
+				if sc.isRetriable != nil && !sc.isRetriable(e) {
+					sc.close(subContext)
+					return runStopped, fmt.Errorf("non-retriable connection error: %w", e)
+				}
+				return runReconnecting, e
+			}
+
+			// the connection is closed by the server, so the client can't continue unless onError recovers
+			var closeErr *WebsocketCloseError
+			if sc.onError == nil && errors.As(e, &closeErr) {
+				sc.close(subContext)
+				return runStopped, e
 			}
 
 			if sc.onError != nil {
 				if err := sc.onError(sc, e); err != nil {
 					sc.close(subContext)
-					return err
-				} else {
-					return sc.Run()
+					return runStopped, err
 				}
+				return runReconnecting, e
 			}
 		}
 	}
 }
 
+// read reads the messages of the connection and passes them to the subscriptions until the session ends
+func (sc *SubscriptionClient) read(subContext *SubscriptionContext, conn WebsocketConn) {
+	ctx := subContext.GetContext()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			var message OperationMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				// manual EOF check
+				if err == io.EOF || strings.Contains(err.Error(), "EOF") || strings.Contains(err.Error(), "connection reset by peer") {
+					sc.sendError(subContext, newRetryError(err))
+					return
+				}
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+
+				closeStatus := conn.GetCloseStatus(err)
+
+				for _, retryCode := range subContext.retryStatusCodes {
+					if (len(retryCode) == 1 && retryCode[0] == closeStatus) ||
+						(len(retryCode) >= 2 && retryCode[0] <= closeStatus && closeStatus <= retryCode[1]) {
+						sc.sendError(subContext, newRetryError(newWebsocketCloseError(closeStatus, err)))
+						return
+					}
+				}
+
+				switch websocket.StatusCode(closeStatus) {
+				case websocket.StatusBadGateway, websocket.StatusNoStatusRcvd:
+					sc.sendError(subContext, newRetryError(err))
+					return
+				case websocket.StatusNormalClosure, websocket.StatusAbnormalClosure:
+					// close event from websocket client, exiting...
+					subContext.Cancel()
+					return
+				case StatusInvalidMessage, StatusConnectionInitialisationTimeout, StatusTooManyInitialisationRequests, StatusSubscriberAlreadyExists, StatusUnauthorized:
+					subContext.Log(err, "server", GQL_CONNECTION_ERROR)
+					sc.sendError(subContext, newWebsocketCloseError(closeStatus, err))
+					return
+				}
+
+				if sc.onError != nil {
+					if err = sc.onError(sc, err); err != nil {
+						// end the subscription if the callback return error
+						subContext.Cancel()
+						return
+					}
+				}
+				continue
+			}
+			subContext.receive(message.Type)
+
+			sub := subContext.GetSubscription(message.ID)
+			if sub != nil && sub.handle != nil {
+				// deliver the messages of the subscription in order
+				sub.handle.enqueue(queuedMessage{ctx: subContext, sub: *sub, message: message}, sc.deliver)
+				continue
+			}
+			if sub == nil {
+				sub = &Subscription{}
+			}
+			item := queuedMessage{ctx: subContext, sub: *sub, message: message}
+			sc.goroutines.Go(func() {
+				sc.deliver(item)
+			})
+		}
+	}
+}
+
+// sessionError is an error of the session of ctx, which is passed to the supervisor
+type sessionError struct {
+	ctx *SubscriptionContext
+	err error
+}
+
+// sendError passes err to the supervisor, unless the session of ctx has ended
+func (sc *SubscriptionClient) sendError(ctx *SubscriptionContext, err error) {
+	se := sessionError{ctx: ctx, err: err}
+	sessionCtx := ctx.GetContext()
+	if sessionCtx == nil {
+		sc.errorChan <- se
+		return
+	}
+	// errors of a replaced session are discarded, see refresh
+	select {
+	case <-sessionCtx.Done():
+		return
+	default:
+	}
+	select {
+	case sc.errorChan <- se:
+	case <-sessionCtx.Done():
+	}
+}
+
+// goroutineGroup counts the running goroutines of the client, so RunContext and Close can wait for them to exit.
+// Unlike sync.WaitGroup, goroutines may be started while waiting
+type goroutineGroup struct {
+	mutex sync.Mutex
+	count int
+	// idle is closed when the count drops to zero
+	idle chan struct{}
+}
+
+// Go runs fn in a new goroutine of the group
+func (g *goroutineGroup) Go(fn func()) {
+	g.mutex.Lock()
+	if g.count == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.count++
+	g.mutex.Unlock()
+
+	go func() {
+		defer g.done()
+		fn()
+	}()
+}
+
+func (g *goroutineGroup) done() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.count--
+	if g.count == 0 {
+		close(g.idle)
+	}
+}
+
+// Wait waits until no goroutine of the group is running, or reports false if timeout elapses first
+func (g *goroutineGroup) Wait(timeout time.Duration) bool {
+	g.mutex.Lock()
+	idle := g.idle
+	count := g.count
+	g.mutex.Unlock()
+	if count == 0 {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// waitGoroutines waits for the reader and handler goroutines of the client to exit, up to the write timeout,
+// so a stuck handler, or Close called from a handler, which waits for its own goroutine, doesn't block forever
+func (sc *SubscriptionClient) waitGoroutines() {
+	if sc.goroutines.Wait(sc.GetTimeout()) {
+		return
+	}
+	if ctx := sc.getContext(); ctx != nil {
+		ctx.Log(fmt.Sprintf("the goroutines of the client didn't exit within %s", sc.GetTimeout()), "client", GQLInternal)
+	}
+}
+
 // close the running websocket connection and reset all subscription states
 func (sc *SubscriptionClient) reset() {
 	subContext := sc.getContext()
 	// fork a new subscription context to start a new session
 	// avoid conflicting with the last running session what is shutting down
+	newContext := forkContext(subContext)
+
+	for _, sub := range subContext.GetSubscriptions() {
+		if sub.status == SubscriptionRunning {
+			sc.protocol.Unsubscribe(subContext, sub)
+		}
+	}
+
+	sc.protocol.Close(subContext)
+	subContext.Close()
+
+	sc.setClientStatus(scStatusInitializing)
+	sc.setContext(newContext)
+}
+
+// forkContext returns a new subscription context with the events and the subscriptions of subContext,
+// except the ones unsubscribed by the user. The subscriptions wait for the new session to start
+func forkContext(subContext *SubscriptionContext) *SubscriptionContext {
 	newContext := &SubscriptionContext{
 		OnConnected:            subContext.OnConnected,
 		OnDisconnected:         subContext.OnDisconnected,
+		OnConnectionAlive:      subContext.OnConnectionAlive,
+		OnConnectionAck:        subContext.OnConnectionAck,
 		OnSubscriptionComplete: subContext.OnSubscriptionComplete,
 		disabledLogTypes:       subContext.disabledLogTypes,
 		log:                    subContext.log,
@@ -824,9 +1478,6 @@ func (sc *SubscriptionClient) reset() {
 		if sub.status == SubscriptionUnsubcribed {
 			continue
 		}
-		if sub.status == SubscriptionRunning {
-			sc.protocol.Unsubscribe(subContext, sub)
-		}
 
 		// should restart subscriptions with new id
 		// to avoid subscription id conflict errors from the server
@@ -834,17 +1485,17 @@ func (sc *SubscriptionClient) reset() {
 		sub.status = SubscriptionWaiting
 		newContext.SetSubscription(key, &sub)
 	}
-
-	sc.protocol.Close(subContext)
-	subContext.Close()
-
-	sc.setClientStatus(scStatusInitializing)
-	sc.setContext(newContext)
+	return newContext
 }
 
-// Close closes all subscription channel and websocket as well
+// Close closes all subscription channel and websocket as well.
+// It waits for the reader and handler goroutines of the client to exit, up to the timeout set by WithTimeout.
+// Called from handlers or event callbacks, it waits for its own goroutine until the timeout,
+// so return ErrSubscriptionStopped from the handler to close the client without the delay
 func (sc *SubscriptionClient) Close() (err error) {
-	return sc.close(sc.getContext())
+	err = sc.close(sc.getContext())
+	sc.waitGoroutines()
+	return err
 }
 
 func (sc *SubscriptionClient) close(ctx *SubscriptionContext) (err error) {
@@ -862,6 +1513,7 @@ func (sc *SubscriptionClient) close(ctx *SubscriptionContext) (err error) {
 
 	for key, sub := range ctx.GetSubscriptions() {
 		ctx.SetSubscription(key, nil)
+		sub.handle.finish(ErrSubscriptionClientClosed)
 		if conn == nil {
 			continue
 		}
@@ -948,6 +1600,8 @@ func parseInt32Ranges(codes []string) ([][]int32, error) {
 type WebsocketHandler struct {
 	ctx     context.Context
 	timeout time.Duration
+	// readTimeout is the read deadline of each message, disabled if zero
+	readTimeout time.Duration
 	*websocket.Conn
 }
 
@@ -961,7 +1615,10 @@ func (wh *WebsocketHandler) WriteJSON(v interface{}) error {
 
 // ReadJSON implements the function to decode the json message from the server
 func (wh *WebsocketHandler) ReadJSON(v interface{}) error {
-	ctx, cancel := context.WithTimeout(wh.ctx, wh.timeout)
+	if wh.readTimeout <= 0 {
+		return wsjson.Read(wh.ctx, wh.Conn, v)
+	}
+	ctx, cancel := context.WithTimeout(wh.ctx, wh.readTimeout)
 	defer cancel()
 	return wsjson.Read(ctx, wh.Conn, v)
 }
@@ -1002,15 +1659,19 @@ func newWebsocketConn(sc *SubscriptionClient) (WebsocketConn, error) {
 		HTTPClient:   sc.websocketOptions.HTTPClient,
 	}
 
-	c, _, err := websocket.Dial(sc.GetContext(), sc.GetURL(), options)
+	c, resp, err := websocket.Dial(sc.GetContext(), sc.GetURL(), options)
 	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return nil, &WebsocketDialError{StatusCode: resp.StatusCode, Err: err}
+		}
 		return nil, err
 	}
 
 	return &WebsocketHandler{
-		ctx:     sc.GetContext(),
-		Conn:    c,
-		timeout: sc.GetTimeout(),
+		ctx:         sc.GetContext(),
+		Conn:        c,
+		timeout:     sc.GetTimeout(),
+		readTimeout: sc.GetReadTimeout(),
 	}, nil
 }
 
@@ -0,0 +1,111 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffPolicy decides the delays between the connection attempts of the subscription client
+type BackoffPolicy interface {
+	// NextDelay returns the delay before the attempt-th reconnection attempt, starting at 1,
+	// or false to stop reconnecting
+	NextDelay(attempt int) (time.Duration, bool)
+}
+
+// ConstantBackoff waits the same interval before every attempt. It's the default policy, with 1 second interval
+type ConstantBackoff struct {
+	Interval time.Duration
+	// MaxAttempts is the maximum number of consecutive attempts, unlimited if zero
+	MaxAttempts int
+}
+
+// NextDelay implements BackoffPolicy
+func (b ConstantBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	return b.Interval, true
+}
+
+// ExponentialBackoff multiplies the delay after every attempt, up to MaxInterval.
+// Jitter randomizes the delays, so many clients that lose the connection at the same time don't reconnect in lockstep
+type ExponentialBackoff struct {
+	// InitialInterval is the delay before the first attempt, 1 second if zero
+	InitialInterval time.Duration
+	// MaxInterval caps the delays, unlimited if zero
+	MaxInterval time.Duration
+	// Multiplier is the factor of the delay of each attempt to the previous one, 2 if zero
+	Multiplier float64
+	// Jitter is the randomization factor between 0 and 1. The delay d is randomized in [d*(1-Jitter), d*(1+Jitter)]
+	Jitter float64
+	// MaxAttempts is the maximum number of consecutive attempts, unlimited if zero
+	MaxAttempts int
+}
+
+// NextDelay implements BackoffPolicy
+func (b ExponentialBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	initial := b.InitialInterval
+	if initial <= 0 {
+		initial = time.Second
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.MaxInterval > 0 {
+		delay = math.Min(delay, float64(b.MaxInterval))
+	}
+	if jitter := math.Min(b.Jitter, 1); jitter > 0 {
+		delay *= 1 - jitter + 2*jitter*randomFloat()
+		if b.MaxInterval > 0 {
+			delay = math.Min(delay, float64(b.MaxInterval))
+		}
+	}
+	if delay >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(delay), true
+}
+
+// jitterRand is seeded per process, so clients of different processes get different delays
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{
+	Rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+}
+
+func randomFloat() float64 {
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return jitterRand.Float64()
+}
+
+// WebsocketDialError is returned by the default websocket client when the server rejects the websocket upgrade,
+// e.g. with 401 Unauthorized. Use it to classify the error as non-retriable, see WithRetryClassifier
+type WebsocketDialError struct {
+	// StatusCode is the HTTP status code of the response to the upgrade request
+	StatusCode int
+	Err        error
+}
+
+// Error implements error interface.
+func (e *WebsocketDialError) Error() string {
+	return fmt.Sprintf("failed to dial the websocket, status code %d: %v", e.StatusCode, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *WebsocketDialError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,139 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// SubscriptionEventType represents the type of events of channel subscriptions
+type SubscriptionEventType int
+
+const (
+	// SubscriptionEventData the event carries the data of a message
+	SubscriptionEventData SubscriptionEventType = iota
+	// SubscriptionEventError the event carries an error of the subscription, e.g. GraphQL errors of a message
+	SubscriptionEventError
+	// SubscriptionEventComplete the subscription is completed by the server, unsubscribed or closed with the client.
+	// It's the last event before the channel is closed
+	SubscriptionEventComplete
+)
+
+// SubscriptionEvent is an event of a channel subscription, see SubscribeChan
+type SubscriptionEvent struct {
+	Type SubscriptionEventType
+	// Data is the raw data of the message, for SubscriptionEventData events
+	Data []byte
+	// Error is the error of SubscriptionEventError events
+	Error error
+}
+
+// SubscribeChan sends start message to server and returns a channel of the events of the subscription.
+// The subscription is unsubscribed when ctx is cancelled, and the channel is closed when the subscription
+// is completed, unsubscribed or closed with the client. Receive the events until the channel is closed,
+// or cancel ctx to stop receiving them.
+//
+// The channel is buffered by the queue size of WithDeliveryQueue. When it's full, data events are dropped
+// by the dropping policies. With DeliveryBlock, the default, the delivery waits until the events are received,
+// which stalls the reader of the connection once the delivery queue is full too, so keep receiving the events
+func (sc *SubscriptionClient) SubscribeChan(ctx context.Context, v interface{}, variables interface{}, options ...Option) (<-chan SubscriptionEvent, error) {
+	stream := newSubscriptionStream(sc.deliveryQueueSize, sc.deliveryPolicy)
+	handle, err := sc.do(v, variables, stream.handle, options...)
+	if err != nil {
+		return nil, err
+	}
+	stream.setHandle(handle)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.close()
+			_ = handle.Unsubscribe()
+		case <-handle.Done():
+			stream.complete()
+		}
+	}()
+
+	return stream.events, nil
+}
+
+// subscriptionStream delivers the messages of a subscription to a buffered channel until it's closed
+type subscriptionStream struct {
+	events chan SubscriptionEvent
+	done   chan struct{}
+	policy DeliveryPolicy
+	// subscription counts the dropped data events, it's set after subscribing
+	subscription *SubscriptionHandle
+	// mutex is held for reading by senders, so the channel is closed after they return
+	mutex sync.RWMutex
+	once  sync.Once
+}
+
+func newSubscriptionStream(size int, policy DeliveryPolicy) *subscriptionStream {
+	if size <= 0 {
+		size = defaultDeliveryQueueSize
+	}
+	return &subscriptionStream{
+		events: make(chan SubscriptionEvent, size),
+		done:   make(chan struct{}),
+		policy: policy,
+	}
+}
+
+// setHandle sets the handle of the subscription that counts the dropped data events
+func (s *subscriptionStream) setHandle(handle *SubscriptionHandle) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscription = handle
+}
+
+func (s *subscriptionStream) handle(data []byte, err error) error {
+	if err != nil {
+		s.send(SubscriptionEvent{Type: SubscriptionEventError, Error: err})
+	} else {
+		s.send(SubscriptionEvent{Type: SubscriptionEventData, Data: data})
+	}
+	return nil
+}
+
+// send blocks until the event is buffered or the stream is closed.
+// Data events that don't fit in the buffer are dropped instead, unless the policy is DeliveryBlock
+func (s *subscriptionStream) send(event SubscriptionEvent) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	if event.Type == SubscriptionEventData && s.policy != DeliveryBlock {
+		select {
+		case s.events <- event:
+		default:
+			if s.subscription != nil {
+				atomic.AddUint64(&s.subscription.dropped, 1)
+			}
+		}
+		return
+	}
+	select {
+	case s.events <- event:
+	case <-s.done:
+	}
+}
+
+// complete sends the complete event and closes the stream
+func (s *subscriptionStream) complete() {
+	s.send(SubscriptionEvent{Type: SubscriptionEventComplete})
+	s.close()
+}
+
+// close closes the channel once, after the pending senders return
+func (s *subscriptionStream) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mutex.Lock()
+		close(s.events)
+		s.mutex.Unlock()
+	})
+}
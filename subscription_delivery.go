@@ -0,0 +1,146 @@
+package graphql
+
+import (
+	"sync"
+)
+
+// DeliveryPolicy decides what happens when a message arrives while the delivery queue of its subscription is full
+type DeliveryPolicy int
+
+const (
+	// DeliveryBlock blocks reading messages from the websocket until the queue has room. No message is dropped.
+	// The connection has a single reader, so a slow handler stalls all subscriptions of the connection,
+	// and the keepalive checks are paused until the queue has room
+	DeliveryBlock DeliveryPolicy = iota
+	// DeliveryDropOldest drops the oldest queued message to make room for the new message
+	DeliveryDropOldest
+	// DeliveryDropNewest drops the new message
+	DeliveryDropNewest
+	// DeliveryLatest drops all queued messages, so the handler receives the latest message next
+	DeliveryLatest
+)
+
+// defaultDeliveryQueueSize is the default number of messages that are queued per subscription
+const defaultDeliveryQueueSize = 100
+
+// queuedMessage is a message that waits for the delivery to its subscription
+type queuedMessage struct {
+	ctx     *SubscriptionContext
+	sub     Subscription
+	message OperationMessage
+}
+
+// deliveryQueue delivers the messages of a subscription to its handler one by one, in the order they arrive.
+// The worker goroutine is started when a message is queued and stops when the queue is empty,
+// so there's at most one worker per subscription.
+// Only data messages count towards the size and are dropped; errors and completion are always delivered
+type deliveryQueue struct {
+	mutex   sync.Mutex
+	room    *sync.Cond
+	items   []queuedMessage
+	data    int
+	size    int
+	policy  DeliveryPolicy
+	running bool
+	// goroutines tracks the worker, so the client can wait for it to exit
+	goroutines *goroutineGroup
+}
+
+func newDeliveryQueue(size int, policy DeliveryPolicy, goroutines *goroutineGroup) *deliveryQueue {
+	if size <= 0 {
+		size = defaultDeliveryQueueSize
+	}
+	q := &deliveryQueue{
+		size:       size,
+		policy:     policy,
+		goroutines: goroutines,
+	}
+	q.room = sync.NewCond(&q.mutex)
+	return q
+}
+
+// push queues the message and starts the worker that passes the queued messages to deliver if it isn't running.
+// It returns the number of dropped messages
+func (q *deliveryQueue) push(item queuedMessage, deliver func(queuedMessage)) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	isData := isDataMessage(item.message.Type)
+	dropped := 0
+	if isData && q.data >= q.size {
+		switch q.policy {
+		case DeliveryDropNewest:
+			return 1
+		case DeliveryDropOldest:
+			dropped = q.dropData(1)
+		case DeliveryLatest:
+			dropped = q.dropData(q.data)
+		default:
+			// the worker is running while there are queued messages, so the room is eventually freed
+			if item.ctx != nil {
+				item.ctx.setReaderBlocked(true)
+				defer item.ctx.setReaderBlocked(false)
+			}
+			for q.data >= q.size {
+				q.room.Wait()
+			}
+		}
+	}
+
+	q.items = append(q.items, item)
+	if isData {
+		q.data++
+	}
+	if !q.running {
+		q.running = true
+		q.goroutines.Go(func() {
+			q.run(deliver)
+		})
+	}
+	return dropped
+}
+
+// dropData removes the n oldest data messages from the queue and returns the number of removed messages
+func (q *deliveryQueue) dropData(n int) int {
+	dropped := 0
+	items := q.items[:0]
+	for _, item := range q.items {
+		if dropped < n && isDataMessage(item.message.Type) {
+			dropped++
+			continue
+		}
+		items = append(items, item)
+	}
+	for i := len(items); i < len(q.items); i++ {
+		q.items[i] = queuedMessage{}
+	}
+	q.items = items
+	q.data -= dropped
+	return dropped
+}
+
+func (q *deliveryQueue) run(deliver func(queuedMessage)) {
+	for {
+		q.mutex.Lock()
+		if len(q.items) == 0 {
+			q.running = false
+			q.mutex.Unlock()
+			return
+		}
+		item := q.items[0]
+		q.items[0] = queuedMessage{}
+		q.items = q.items[1:]
+		if isDataMessage(item.message.Type) {
+			q.data--
+			q.room.Broadcast()
+		}
+		q.mutex.Unlock()
+
+		deliver(item)
+	}
+}
+
+// isDataMessage reports whether the message carries the data of a subscription
+func isDataMessage(t OperationMessageType) bool {
+	return t == GQLData || t == GQLNext
+}
@@ -42,6 +42,11 @@ func (gws *graphqlWS) ConnectionInit(ctx *SubscriptionContext, connectionParams
 	return connectionInit(ctx, connectionParams)
 }
 
+// Ping sends a ping message to the server, which responds with pong
+func (gws *graphqlWS) Ping(ctx *SubscriptionContext) error {
+	return ctx.Send(OperationMessage{Type: GQLPing}, GQLPing)
+}
+
 // Subscribe requests an graphql operation specified in the payload message
 func (gws *graphqlWS) Subscribe(ctx *SubscriptionContext, sub Subscription) error {
 	if sub.GetStatus() == SubscriptionRunning {
@@ -136,6 +141,7 @@ func (gws *graphqlWS) OnMessage(ctx *SubscriptionContext, subscription Subscript
 		}
 		if sub != nil {
 			ctx.SetSubscription(sub.GetKey(), nil)
+			sub.handle.finish(ErrSubscriptionCompleted)
 		}
 	case GQLPing:
 		ctx.Log(message, "server", GQLPing)
@@ -151,11 +157,19 @@ func (gws *graphqlWS) OnMessage(ctx *SubscriptionContext, subscription Subscript
 		if err := ctx.Send(msg, GQLPong); err != nil {
 			ctx.Log(err, "client", GQLInternal)
 		}
+	case GQLPong:
+		ctx.Log(message, "server", GQLPong)
+		if ctx.OnConnectionAlive != nil {
+			ctx.OnConnectionAlive()
+		}
 	case GQLConnectionAck:
 		// Expected response to the ConnectionInit message from the client acknowledging a successful connection with the server.
 		// The client is now ready to request subscription operations.
 		ctx.Log(message, "server", GQLConnectionAck)
 		ctx.SetAcknowledge(true)
+		if ctx.OnConnectionAck != nil {
+			ctx.OnConnectionAck(message.Payload)
+		}
 		for id, sub := range ctx.GetSubscriptions() {
 			if err := gws.Subscribe(ctx, sub); err != nil {
 				ctx.Log(fmt.Sprintf("failed to subscribe: %s; id: %s; query: %s", err, id, sub.payload.Query), "client", GQLInternal)
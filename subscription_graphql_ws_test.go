@@ -280,7 +280,7 @@ func TestGraphqlWS_SubscriptionRerun(t *testing.T) {
 	time.Sleep(2 * time.Second)
 	go func() {
 		time.Sleep(2 * time.Second)
-		subscriptionClient.Unsubscribe(subId1)
+		subId1.Unsubscribe()
 	}()
 
 	if err := subscriptionClient.Run(); err != nil {
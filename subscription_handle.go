@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// buildSubscription returns the query and operation name of a subscription for its variables
+type buildSubscription func(variables map[string]interface{}) (query string, operationName string, err error)
+
+// SubscriptionStats are the statistics of the messages of a subscription
+type SubscriptionStats struct {
+	// Messages is the number of messages that are passed to the handler, including errors
+	Messages uint64
+	// Errors is the number of errors that are passed to the handler
+	Errors uint64
+	// Dropped is the number of data messages that are dropped because the delivery queue was full, see WithDeliveryQueue
+	Dropped uint64
+	// LastMessageAt is the time of the last message, or zero if there isn't any
+	LastMessageAt time.Time
+}
+
+// SubscriptionHandle is the handle of a subscription, returned by Subscribe.
+// Its ID is the identity of the subscription across reconnects,
+// while the operation id that is sent to the server is regenerated whenever the client reconnects
+type SubscriptionHandle struct {
+	// the counters are accessed atomically, so they are 64-bit aligned at the beginning of the struct
+	messages      uint64
+	errors        uint64
+	dropped       uint64
+	lastMessageAt int64
+
+	client *SubscriptionClient
+	key    string
+	build  buildSubscription
+	queue  *deliveryQueue
+	done   chan struct{}
+	err    error
+	once   sync.Once
+}
+
+func newSubscriptionHandle(client *SubscriptionClient, build buildSubscription) *SubscriptionHandle {
+	return &SubscriptionHandle{
+		client: client,
+		key:    uuid.New().String(),
+		build:  build,
+		queue:  newDeliveryQueue(client.deliveryQueueSize, client.deliveryPolicy, &client.goroutines),
+		done:   make(chan struct{}),
+	}
+}
+
+// ID returns the identity of the subscription, which doesn't change across reconnects.
+// It's the key of the subscription in the context and can be passed to SubscriptionClient.Unsubscribe
+func (h *SubscriptionHandle) ID() string {
+	return h.key
+}
+
+// Unsubscribe sends stop message to server and ends the subscription
+func (h *SubscriptionHandle) Unsubscribe() error {
+	return h.client.Unsubscribe(h.key)
+}
+
+// Done returns a channel that is closed when the subscription ends,
+// i.e. it's completed by the server, unsubscribed or closed with the client
+func (h *SubscriptionHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns nil if the subscription hasn't ended yet, otherwise the reason why it ended:
+// ErrSubscriptionCompleted, ErrSubscriptionUnsubscribed or ErrSubscriptionClientClosed
+func (h *SubscriptionHandle) Err() error {
+	select {
+	case <-h.done:
+		return h.err
+	default:
+		return nil
+	}
+}
+
+// Status returns the current status of the subscription
+func (h *SubscriptionHandle) Status() SubscriptionStatus {
+	switch err := h.Err(); {
+	case errors.Is(err, ErrSubscriptionUnsubscribed):
+		return SubscriptionUnsubcribed
+	case err != nil:
+		return SubscriptionCompleted
+	}
+	if ctx := h.client.getContext(); ctx != nil {
+		if sub := ctx.GetSubscription(h.key); sub != nil {
+			return sub.status
+		}
+	}
+	return SubscriptionWaiting
+}
+
+// Stats returns the statistics of the messages of the subscription
+func (h *SubscriptionHandle) Stats() SubscriptionStats {
+	stats := SubscriptionStats{
+		Messages: atomic.LoadUint64(&h.messages),
+		Errors:   atomic.LoadUint64(&h.errors),
+		Dropped:  atomic.LoadUint64(&h.dropped),
+	}
+	if ts := atomic.LoadInt64(&h.lastMessageAt); ts > 0 {
+		stats.LastMessageAt = time.Unix(0, ts)
+	}
+	return stats
+}
+
+// Resubscribe replaces the variables of the subscription, keeping its identity and handler.
+// The query is constructed again, so the types of the variables may change.
+// If the subscription is running, it's stopped and started again with the new variables
+func (h *SubscriptionHandle) Resubscribe(variables interface{}) error {
+	if h.Err() != nil {
+		return fmt.Errorf("%s, %w", h.key, ErrSubscriptionNotExists)
+	}
+	vars, err := variablesMap(variables)
+	if err != nil {
+		return err
+	}
+	query, operationName, err := h.build(vars)
+	if err != nil {
+		return err
+	}
+	return h.client.resubscribe(h.key, GraphQLRequestPayload{
+		Query:         query,
+		Variables:     encodeVariables(vars),
+		OperationName: operationName,
+	})
+}
+
+// enqueue queues the message for the delivery worker of the subscription and counts the dropped messages
+func (h *SubscriptionHandle) enqueue(item queuedMessage, deliver func(queuedMessage)) {
+	if dropped := h.queue.push(item, deliver); dropped > 0 {
+		atomic.AddUint64(&h.dropped, uint64(dropped))
+	}
+}
+
+// record counts a message that is passed to the handler
+func (h *SubscriptionHandle) record(err error) {
+	atomic.AddUint64(&h.messages, 1)
+	if err != nil {
+		atomic.AddUint64(&h.errors, 1)
+	}
+	atomic.StoreInt64(&h.lastMessageAt, time.Now().UnixNano())
+}
+
+// finish ends the subscription with the reason err. Only the first reason is kept
+func (h *SubscriptionHandle) finish(err error) {
+	if h == nil {
+		return
+	}
+	h.once.Do(func() {
+		h.err = err
+		close(h.done)
+	})
+}
@@ -0,0 +1,157 @@
+package graphql
+
+import (
+	"fmt"
+	"time"
+)
+
+// SubscriptionPinger is implemented by protocols that let the client ping the server, see WithPing.
+// The server must respond to the ping with a pong message
+type SubscriptionPinger interface {
+	// Ping sends a ping message to the server
+	Ping(ctx *SubscriptionContext) error
+}
+
+// WithReadTimeout sets the maximum duration the default websocket client waits for a message,
+// which defaults to the write timeout set by WithTimeout. When it's exceeded, the client pings the server
+// and reconnects if the server doesn't respond.
+// A negative timeout disables the read deadline, e.g. if WithIdleTimeout or WithPing detects dead connections
+func (sc *SubscriptionClient) WithReadTimeout(timeout time.Duration) *SubscriptionClient {
+	sc.readTimeout = timeout
+	return sc
+}
+
+// GetReadTimeout returns read timeout of websocket client, zero if the read deadline is disabled
+func (sc *SubscriptionClient) GetReadTimeout() time.Duration {
+	if sc.readTimeout == 0 {
+		return sc.timeout
+	}
+	if sc.readTimeout < 0 {
+		return 0
+	}
+	return sc.readTimeout
+}
+
+// WithIdleTimeout reconnects when no keepalive message is received from the server within timeout:
+// ka of the subscriptions-transport-ws protocol, or ping and pong of graphql-ws. Data messages don't count.
+// Servers of the subscriptions-transport-ws protocol send ka messages periodically,
+// so the timeout should be a few intervals of the server. Zero disables the check, which is the default
+func (sc *SubscriptionClient) WithIdleTimeout(timeout time.Duration) *SubscriptionClient {
+	sc.idleTimeout = timeout
+	return sc
+}
+
+// WithPing pings the server when no pong is received for interval,
+// and reconnects if the server doesn't respond with pong within timeout, which defaults to interval.
+// It requires a protocol that implements SubscriptionPinger, e.g. graphql-ws.
+// Zero interval disables pings, which is the default
+func (sc *SubscriptionClient) WithPing(interval time.Duration, timeout time.Duration) *SubscriptionClient {
+	sc.pingInterval = interval
+	sc.pingTimeout = timeout
+	return sc
+}
+
+// WithConnectionAckTimeout reconnects when the server doesn't acknowledge the connection within timeout
+// after the connection init message. The cause of reconnecting is ErrConnectionAckTimeout,
+// which stops the client instead if WithRetryClassifier classifies it as non-retriable.
+// Zero disables the timeout, which is the default
+func (sc *SubscriptionClient) WithConnectionAckTimeout(timeout time.Duration) *SubscriptionClient {
+	sc.ackTimeout = timeout
+	return sc
+}
+
+// waitConnectionAck reconnects if the session isn't acknowledged within the ack timeout
+func (sc *SubscriptionClient) waitConnectionAck(subContext *SubscriptionContext) {
+	timer := time.NewTimer(sc.ackTimeout)
+	defer timer.Stop()
+	select {
+	case <-subContext.GetContext().Done():
+	case <-timer.C:
+		if !subContext.GetAcknowledge() {
+			sc.sendError(subContext, newRetryError(fmt.Errorf("%w after %s", ErrConnectionAckTimeout, sc.ackTimeout)))
+		}
+	}
+}
+
+// keepAlive checks that the connection of the session is alive until the session ends.
+// The idle timeout is checked against the keepalive messages of the server, and pings are answered by pong only.
+// If it's dead, the supervisor reconnects with ErrKeepAliveTimeout
+func (sc *SubscriptionClient) keepAlive(subContext *SubscriptionContext) {
+	pinger, ok := sc.protocol.(SubscriptionPinger)
+	if !ok || sc.pingInterval <= 0 {
+		pinger = nil
+	}
+	if pinger == nil && sc.idleTimeout <= 0 {
+		return
+	}
+	pingTimeout := sc.pingTimeout
+	if pingTimeout <= 0 {
+		pingTimeout = sc.pingInterval
+	}
+
+	// recheck is the interval of checking whether the reader is still blocked by a full delivery queue
+	recheck := sc.idleTimeout
+	if pinger != nil && (recheck <= 0 || sc.pingInterval < recheck) {
+		recheck = sc.pingInterval
+	}
+
+	ctx := subContext.GetContext()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	// pingedAt is the time of the ping that hasn't been responded, zero if there's none
+	var pingedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		if subContext.isReaderBlocked() {
+			// keepalive messages can't be read while the reader is blocked by a full delivery queue
+			pingedAt = time.Time{}
+			timer.Reset(recheck)
+			continue
+		}
+		var next time.Time
+		if sc.idleTimeout > 0 {
+			last := subContext.lastKeepAlive()
+			if now.Sub(last) >= sc.idleTimeout {
+				sc.sendError(subContext, newRetryError(fmt.Errorf("%w: no keepalive message received in %s", ErrKeepAliveTimeout, sc.idleTimeout)))
+				return
+			}
+			next = last.Add(sc.idleTimeout)
+		}
+
+		if pinger != nil {
+			last := subContext.lastPong()
+			if !pingedAt.IsZero() && last.Before(pingedAt) {
+				if now.Sub(pingedAt) >= pingTimeout {
+					sc.sendError(subContext, newRetryError(fmt.Errorf("%w: no response to ping in %s", ErrKeepAliveTimeout, pingTimeout)))
+					return
+				}
+				next = earliest(next, pingedAt.Add(pingTimeout))
+			} else if now.Sub(last) >= sc.pingInterval {
+				if err := pinger.Ping(subContext); err != nil {
+					subContext.Log(fmt.Sprintf("failed to ping the server: %s", err), "client", GQLInternal)
+				}
+				pingedAt = now
+				next = earliest(next, now.Add(pingTimeout))
+			} else {
+				pingedAt = time.Time{}
+				next = earliest(next, last.Add(sc.pingInterval))
+			}
+		}
+
+		timer.Reset(time.Until(next))
+	}
+}
+
+// earliest returns the earliest of the times, ignoring zero a
+func earliest(a time.Time, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
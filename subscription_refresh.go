@@ -0,0 +1,144 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithRefreshInterval refreshes the connection every interval, e.g. shortly before the token
+// in the connection params expires, see RefreshConnection. Zero disables it, which is the default
+func (sc *SubscriptionClient) WithRefreshInterval(interval time.Duration) *SubscriptionClient {
+	sc.refreshInterval = interval
+	return sc
+}
+
+// WithReauth sets the hook that re-authenticates the current connection in-band when the connection is refreshed,
+// for servers that accept re-authentication messages. It receives the fresh connection params,
+// and sends the message of the server with ctx.Send. If it returns an error, a new connection is opened instead
+func (sc *SubscriptionClient) WithReauth(fn func(ctx *SubscriptionContext, connectionParams map[string]interface{}) error) *SubscriptionClient {
+	sc.reauth = fn
+	return sc
+}
+
+// RefreshConnection replaces the connection of the running client with a new connection with fresh connection params,
+// e.g. when the token in the connection params is renewed. The new connection is opened before the current one is closed:
+// the subscriptions are started on the new connection when the server acknowledges it, then they're stopped
+// on the current connection. Messages aren't lost, but the messages sent while the subscriptions run on both connections
+// may be delivered twice, because the protocols don't identify the events.
+// If the new connection fails, the subscriptions keep running on the current connection.
+//
+// The refresh runs in the background, and the hook of WithReauth is tried first if it's set.
+// A pending refresh is abandoned if the current connection fails, or the client is closed or stopped.
+// It's ignored if the client isn't running
+func (sc *SubscriptionClient) RefreshConnection() {
+	if sc.getClientStatus() != scStatusRunning {
+		return
+	}
+	select {
+	case sc.refreshChan <- struct{}{}:
+	default:
+	}
+}
+
+// refresh re-authenticates or replaces the connection of subContext, and returns the context of the running session
+func (sc *SubscriptionClient) refresh(ctx context.Context, subContext *SubscriptionContext) *SubscriptionContext {
+	if sc.reauth != nil {
+		err := sc.reauth(subContext, sc.getConnectionParams())
+		if err == nil {
+			return subContext
+		}
+		subContext.Log(fmt.Sprintf("failed to re-authenticate: %s; opening a new connection", err), "client", GQLInternal)
+	}
+
+	newContext := forkContext(subContext)
+	// the websocket client dials with the current context of the client
+	sc.setContext(newContext)
+	err := sc.dial(newContext)
+	if err == nil {
+		sc.startSession(newContext, newContext.GetWebsocketConn())
+		err = sc.waitAcknowledge(ctx, newContext)
+	}
+
+	if err != nil {
+		subContext.Log(fmt.Sprintf("failed to refresh the connection: %s", err), "client", GQLInternal)
+		sc.protocol.Close(newContext)
+		newContext.terminate()
+		sc.setContext(subContext)
+		if sc.getClientStatus() == scStatusClosing {
+			// the client is closed while refreshing, so the current session is closed too
+			sc.protocol.Close(subContext)
+			subContext.Close()
+			return subContext
+		}
+		sc.restore(newContext, subContext)
+		return subContext
+	}
+
+	// the subscriptions are running on the new connection, so they're stopped on the current connection,
+	// and removed to ignore its remaining messages
+	for key, sub := range subContext.GetSubscriptions() {
+		if sub.status == SubscriptionRunning {
+			if err := sc.protocol.Unsubscribe(subContext, sub); err != nil {
+				subContext.Log(fmt.Sprintf("failed to unsubscribe: %s; id: %s", err, sub.id), "client", GQLInternal)
+			}
+		}
+		subContext.SetSubscription(key, nil)
+	}
+	sc.protocol.Close(subContext)
+	subContext.terminate()
+	return newContext
+}
+
+// waitAcknowledge waits until the server acknowledges the connection of subContext and its subscriptions are started,
+// within the ack timeout, or the write timeout if it isn't set
+func (sc *SubscriptionClient) waitAcknowledge(ctx context.Context, subContext *SubscriptionContext) error {
+	timeout := sc.ackTimeout
+	if timeout <= 0 {
+		timeout = sc.timeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-subContext.started():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-subContext.GetContext().Done():
+		return errors.New("the connection is closed before it's acknowledged")
+	case <-timer.C:
+		return fmt.Errorf("%w after %s", ErrConnectionAckTimeout, timeout)
+	}
+}
+
+// restore applies the changes of the subscriptions of the abandoned context from, which are made while refreshing,
+// to the context to: new subscriptions are started and unsubscribed subscriptions are stopped
+func (sc *SubscriptionClient) restore(from *SubscriptionContext, to *SubscriptionContext) {
+	for key, sub := range from.GetSubscriptions() {
+		current := to.GetSubscription(key)
+		if current == nil {
+			if sub.status == SubscriptionUnsubcribed {
+				continue
+			}
+			sub.status = SubscriptionWaiting
+			to.SetSubscription(key, &sub)
+			if to.GetAcknowledge() {
+				if err := sc.protocol.Subscribe(to, sub); err != nil {
+					to.Log(fmt.Sprintf("failed to subscribe: %s; id: %s; query: %s", err, sub.id, sub.payload.Query), "client", GQLInternal)
+				}
+			}
+			continue
+		}
+		if sub.status == SubscriptionUnsubcribed && current.status != SubscriptionUnsubcribed {
+			if current.status == SubscriptionRunning {
+				if err := sc.protocol.Unsubscribe(to, *current); err != nil {
+					to.Log(fmt.Sprintf("failed to unsubscribe: %s; id: %s", err, current.id), "client", GQLInternal)
+				}
+			}
+			current.status = SubscriptionUnsubcribed
+			to.SetSubscription(key, current)
+		}
+	}
+}
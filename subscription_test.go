@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -110,7 +114,7 @@ func TestSubscription_LifeCycleEvents(t *testing.T) {
 		})
 
 	for _, f := range fixtures {
-		id, err := subscriptionClient.Subscribe(f.Query, f.Variables, func(data []byte, e error) error {
+		handle, err := subscriptionClient.Subscribe(f.Query, f.Variables, func(data []byte, e error) error {
 			lock.Lock()
 			defer lock.Unlock()
 			if e != nil {
@@ -131,8 +135,8 @@ func TestSubscription_LifeCycleEvents(t *testing.T) {
 		if err != nil {
 			t.Fatalf("got error: %v, want: nil", err)
 		}
-		f.Subscription.id = id
-		log.Printf("subscribed: %s; subscriptions %+v", id, subscriptionClient.context.subscriptions)
+		f.Subscription.key = handle.ID()
+		log.Printf("subscribed: %s; subscriptions %+v", handle.ID(), subscriptionClient.context.subscriptions)
 	}
 
 	go func() {
@@ -164,8 +168,8 @@ func TestSubscription_LifeCycleEvents(t *testing.T) {
 
 		time.Sleep(2 * time.Second)
 		for _, f := range fixtures {
-			log.Println("unsubscribing ", f.Subscription.id)
-			if err := subscriptionClient.Unsubscribe(f.Subscription.id); err != nil {
+			log.Println("unsubscribing ", f.Subscription.key)
+			if err := subscriptionClient.Unsubscribe(f.Subscription.key); err != nil {
 				log.Printf("subscriptions: %+v", subscriptionClient.context.subscriptions)
 				panic(err)
 
@@ -184,7 +188,7 @@ func TestSubscription_LifeCycleEvents(t *testing.T) {
 		t.Fatalf("failed to listen OnSubscriptionComplete event. got %+v, want: %+v", len(subscriptionResults), len(fixtures))
 	}
 	for i, s := range subscriptionResults {
-		if s.id != fixtures[i].Subscription.id {
+		if s.key != fixtures[i].Subscription.key {
 			t.Fatalf("%d: subscription id not matched, got: %s, want: %s", i, s.GetPayload().Query, fixtures[i].Subscription.payload.Query)
 		}
 		if s.GetPayload().Query != fixtures[i].Subscription.payload.Query {
@@ -305,6 +309,1372 @@ func TestSubscription_parseInt32Ranges(t *testing.T) {
 	}
 }
 
+func TestSubscription_typedHandler(t *testing.T) {
+	type query struct {
+		User struct {
+			ID   string
+			Name string
+		}
+	}
+	var received []*query
+	var errs []error
+	sc := NewSubscriptionClient("ws://localhost/graphql")
+	handler := sc.typedHandler(reflect.TypeOf(query{}), func(data interface{}, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		received = append(received, data.(*query))
+		return nil
+	})
+
+	for _, data := range []string{
+		`{"user": {"id": "1", "name": "foo"}}`,
+		`{"user": {"id": "2", "name": "bar"}}`,
+		`{"user": {"id": 3}}`,
+		`null`,
+	} {
+		if err := handler([]byte(data), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := handler(nil, errors.New("stream error")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("got %d values, want 3", len(received))
+	}
+	if received[0].User.Name != "foo" || received[1].User.Name != "bar" {
+		t.Errorf("got %+v and %+v, want fresh values per message", received[0].User, received[1].User)
+	}
+	if received[2].User.ID != "" {
+		t.Errorf("got %+v, want the zero value for null data", received[2].User)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want 2", len(errs))
+	}
+	var decodeErr *SubscriptionDecodeError
+	if !errors.As(errs[0], &decodeErr) {
+		t.Fatalf("got %T, want *SubscriptionDecodeError", errs[0])
+	}
+	if string(decodeErr.Data) != `{"user": {"id": 3}}` {
+		t.Errorf("got data %s", decodeErr.Data)
+	}
+	var jsonErr *DecodeError
+	if !errors.As(errs[0], &jsonErr) || jsonErr.JSONPath != "user.id" {
+		t.Errorf("got %v, want the decode error of user.id", errs[0])
+	}
+	if errs[1].Error() != "stream error" {
+		t.Errorf("got %v, want the stream error", errs[1])
+	}
+	if _, err := sc.SubscribeTyped(query{}, nil, nil); err == nil {
+		t.Error("got nil error, want the query must be a pointer")
+	}
+}
+
+func TestSubscription_subscribeChanCancel(t *testing.T) {
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	sc := NewSubscriptionClient("ws://localhost/graphql")
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := sc.SubscribeChan(ctx, &sub, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := sc.getContext().GetSubscriptionsLength([]SubscriptionStatus{SubscriptionWaiting}); n != 1 {
+		t.Fatalf("got %d waiting subscriptions, want 1", n)
+	}
+
+	cancel()
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Fatalf("got event %+v, want the channel closed", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the channel isn't closed after the context is cancelled")
+	}
+	if n := sc.getContext().GetSubscriptionsLength([]SubscriptionStatus{SubscriptionWaiting}); n != 0 {
+		t.Errorf("got %d waiting subscriptions, want 0", n)
+	}
+}
+
+func TestSubscription_subscriptionStream(t *testing.T) {
+	stream := newSubscriptionStream(0, DeliveryBlock)
+	go func() {
+		stream.handle([]byte(`{"a":1}`), nil)
+		stream.handle(nil, errors.New("failed"))
+		stream.complete()
+		// events after completion are dropped
+		stream.handle([]byte(`{"a":2}`), nil)
+	}()
+
+	var types []SubscriptionEventType
+	for event := range stream.events {
+		types = append(types, event.Type)
+	}
+	want := []SubscriptionEventType{SubscriptionEventData, SubscriptionEventError, SubscriptionEventComplete}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", types, want)
+	}
+
+	// data events that don't fit in the buffer are dropped by the dropping policies
+	dropping := newSubscriptionStream(1, DeliveryDropNewest)
+	dropping.handle([]byte(`{"a":1}`), nil)
+	dropping.handle([]byte(`{"a":2}`), nil)
+	if event := <-dropping.events; string(event.Data) != `{"a":1}` || len(dropping.events) != 0 {
+		t.Errorf("got event %+v and %d buffered events, want the first event only", event, len(dropping.events))
+	}
+
+	// senders blocked on a consumer that stopped receiving return when the stream is closed
+	blocked := newSubscriptionStream(1, DeliveryBlock)
+	sent := make(chan struct{})
+	go func() {
+		blocked.handle([]byte(`{}`), nil)
+		blocked.handle([]byte(`{}`), nil)
+		close(sent)
+	}()
+	blocked.close()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("the sender is blocked after the stream is closed")
+	}
+}
+
+func TestSubscription_Exec(t *testing.T) {
+	sc := NewSubscriptionClient("ws://localhost/graphql")
+	handler := func(data []byte, err error) error {
+		return nil
+	}
+	// graphql-ws executes operations of any type
+	for _, query := range []string{
+		"subscription OnUser { user { id } }",
+		"query GetUser { user { id } }",
+		"mutation { addUser { id } }",
+	} {
+		if _, err := sc.Exec(query, nil, handler); err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+
+	for _, query := range []string{
+		"subscription { user { id }",
+		"type User { id: ID }",
+	} {
+		if _, err := sc.Exec(query, nil, handler); err == nil {
+			t.Errorf("%s: expected error, got nil", query)
+		}
+	}
+}
+
+func TestSubscription_handle(t *testing.T) {
+	var sub struct {
+		User struct {
+			ID string
+		} `graphql:"user(id: $id)"`
+	}
+	sc := NewSubscriptionClient("ws://localhost/graphql")
+	handle, err := sc.Subscribe(&sub, map[string]interface{}{"id": 1}, func(data []byte, err error) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handle.ID() == "" || handle.Status() != SubscriptionWaiting || handle.Err() != nil {
+		t.Fatalf("got id %q, status %d and error %v, want a waiting subscription", handle.ID(), handle.Status(), handle.Err())
+	}
+
+	state := sc.getContext().GetSubscription(handle.ID())
+	state.handler([]byte(`{"user":{"id":"1"}}`), nil)
+	state.handler(nil, errors.New("failed"))
+	stats := handle.Stats()
+	if stats.Messages != 2 || stats.Errors != 1 || stats.LastMessageAt.IsZero() {
+		t.Errorf("got stats %+v, want 2 messages and 1 error", stats)
+	}
+
+	if err := handle.Resubscribe(map[string]interface{}{"id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	state = sc.getContext().GetSubscription(handle.ID())
+	if want := "subscription ($id:String!){user(id: $id){id}}"; state.GetPayload().Query != want {
+		t.Errorf("got query %s, want %s", state.GetPayload().Query, want)
+	}
+	if want := "1"; state.GetPayload().Variables["id"] != want {
+		t.Errorf("got variables %v, want id %s", state.GetPayload().Variables, want)
+	}
+
+	if err := handle.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handle.Done():
+	default:
+		t.Fatal("the handle isn't done after unsubscribing")
+	}
+	if handle.Err() != ErrSubscriptionUnsubscribed || handle.Status() != SubscriptionUnsubcribed {
+		t.Errorf("got error %v and status %d, want unsubscribed", handle.Err(), handle.Status())
+	}
+	if err := handle.Resubscribe(nil); !errors.Is(err, ErrSubscriptionNotExists) {
+		t.Errorf("got %v, want %v", err, ErrSubscriptionNotExists)
+	}
+
+	other, err := sc.Subscribe(&sub, map[string]interface{}{"id": 2}, func(data []byte, err error) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if other.Err() != ErrSubscriptionClientClosed || other.Status() != SubscriptionCompleted {
+		t.Errorf("got error %v and status %d, want closed", other.Err(), other.Status())
+	}
+}
+
+func TestSubscription_deliveryQueue(t *testing.T) {
+	message := func(t OperationMessageType, i int) queuedMessage {
+		return queuedMessage{message: OperationMessage{Type: t, Payload: json.RawMessage(fmt.Sprint(i))}}
+	}
+
+	// run pushes 5 data messages and a complete message while the first delivery is blocked,
+	// then returns the delivered payloads and the number of dropped messages
+	run := func(policy DeliveryPolicy, size int) ([]string, int) {
+		q := newDeliveryQueue(size, policy, &goroutineGroup{})
+		var lock sync.Mutex
+		var delivered []string
+		started := make(chan struct{})
+		release := make(chan struct{})
+		finished := make(chan struct{})
+		concurrent := int32(0)
+		deliver := func(item queuedMessage) {
+			if atomic.AddInt32(&concurrent, 1) > 1 {
+				t.Errorf("%d: concurrent delivery", policy)
+			}
+			defer atomic.AddInt32(&concurrent, -1)
+			if string(item.message.Payload) == "0" {
+				close(started)
+				<-release
+			}
+			lock.Lock()
+			delivered = append(delivered, string(item.message.Payload))
+			lock.Unlock()
+			if item.message.Type == GQLComplete {
+				close(finished)
+			}
+		}
+
+		dropped := q.push(message(GQLData, 0), deliver)
+		<-started
+		pushed := make(chan struct{})
+		go func() {
+			for i := 1; i <= 5; i++ {
+				dropped += q.push(message(GQLNext, i), deliver)
+			}
+			dropped += q.push(message(GQLComplete, 6), deliver)
+			close(pushed)
+		}()
+		if policy == DeliveryBlock {
+			select {
+			case <-pushed:
+				t.Fatal("push isn't blocked while the queue is full")
+			case <-time.After(100 * time.Millisecond):
+			}
+		} else {
+			<-pushed
+		}
+		close(release)
+		<-pushed
+		<-finished
+		return delivered, dropped
+	}
+
+	fixtures := []struct {
+		policy  DeliveryPolicy
+		want    []string
+		dropped int
+	}{
+		{DeliveryBlock, []string{"0", "1", "2", "3", "4", "5", "6"}, 0},
+		{DeliveryDropOldest, []string{"0", "4", "5", "6"}, 3},
+		{DeliveryDropNewest, []string{"0", "1", "2", "6"}, 3},
+		{DeliveryLatest, []string{"0", "5", "6"}, 4},
+	}
+	for _, f := range fixtures {
+		got, dropped := run(f.policy, 2)
+		if fmt.Sprint(got) != fmt.Sprint(f.want) || dropped != f.dropped {
+			t.Errorf("%d: got %v and %d dropped, want %v and %d dropped", f.policy, got, dropped, f.want, f.dropped)
+		}
+	}
+}
+
+// fakeConn is a websocket connection that replays the messages of the server until it's closed
+type fakeConn struct {
+	messages chan OperationMessage
+	closed   chan struct{}
+	once     sync.Once
+	lock     sync.Mutex
+	written  []OperationMessageType
+}
+
+func newFakeConn(messages ...OperationMessage) *fakeConn {
+	conn := &fakeConn{
+		messages: make(chan OperationMessage, len(messages)),
+		closed:   make(chan struct{}),
+	}
+	for _, message := range messages {
+		conn.messages <- message
+	}
+	return conn
+}
+
+func (fc *fakeConn) ReadJSON(v interface{}) error {
+	select {
+	case message := <-fc.messages:
+		*v.(*OperationMessage) = message
+		return nil
+	case <-fc.closed:
+		return context.Canceled
+	}
+}
+
+func (fc *fakeConn) WriteJSON(v interface{}) error {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	if message, ok := v.(OperationMessage); ok {
+		fc.written = append(fc.written, message.Type)
+	}
+	return nil
+}
+
+func (fc *fakeConn) Close() error {
+	fc.once.Do(func() {
+		close(fc.closed)
+	})
+	return nil
+}
+
+func (fc *fakeConn) SetReadLimit(limit int64) {}
+
+func (fc *fakeConn) GetCloseStatus(err error) int32 {
+	return -1
+}
+
+func (fc *fakeConn) writtenTypes() []OperationMessageType {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return append([]OperationMessageType(nil), fc.written...)
+}
+
+// eofConn is a websocket connection that is lost immediately
+type eofConn struct {
+	*fakeConn
+}
+
+func (ec *eofConn) ReadJSON(v interface{}) error {
+	return io.EOF
+}
+
+func TestSubscription_RunContext_reconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dials := 0
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithExitWhenNoSubscription(false).
+		WithBackoff(ConstantBackoff{}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			if dials == 200 {
+				cancel()
+			}
+			return &eofConn{newFakeConn()}, nil
+		})
+
+	// the client reconnects in a loop, so a flapping server doesn't grow the stack
+	if err := sc.RunContext(ctx); err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+	if dials < 200 {
+		t.Errorf("got %d connections, want 200", dials)
+	}
+}
+
+func TestSubscription_RunContext_cancel(t *testing.T) {
+	conn := newFakeConn(OperationMessage{Type: GQLConnectionAck})
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return conn, nil
+		})
+
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	handle, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for handle.Status() != SubscriptionRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the subscription to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext doesn't return after the context is cancelled")
+	}
+
+	// the subscription is stopped gracefully before the connection is closed
+	want := []OperationMessageType{GQLConnectionInit, GQLStart, GQLStop, GQLConnectionTerminate}
+	if got := conn.writtenTypes(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got messages %v, want %v", got, want)
+	}
+	if handle.Err() != ErrSubscriptionClientClosed {
+		t.Errorf("got error: %v, want: %v", handle.Err(), ErrSubscriptionClientClosed)
+	}
+	sc.goroutines.mutex.Lock()
+	count := sc.goroutines.count
+	sc.goroutines.mutex.Unlock()
+	if count != 0 {
+		t.Errorf("got %d running goroutines, want 0", count)
+	}
+}
+
+func TestSubscription_closeFromHandler(t *testing.T) {
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithTimeout(100 * time.Millisecond).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return newServerConn("1", true), nil
+		})
+
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	_, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		// Close waits for the handler goroutines, including this one, until the timeout
+		return sc.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(context.Background())
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("got error: %v, want: nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext doesn't return after the client is closed by the handler")
+	}
+}
+
+func TestSubscription_Close(t *testing.T) {
+	conn := newFakeConn(OperationMessage{Type: GQLConnectionAck})
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return conn, nil
+		})
+
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	handle, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(context.Background())
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for handle.Status() != SubscriptionRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the subscription to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := sc.Close(); err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+	sc.goroutines.mutex.Lock()
+	count := sc.goroutines.count
+	sc.goroutines.mutex.Unlock()
+	if count != 0 {
+		t.Errorf("got %d running goroutines, want 0", count)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("got error: %v, want: nil", err)
+	}
+}
+
+func TestSubscription_RunContext_stuckHandler(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithTimeout(100 * time.Millisecond).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return newServerConn("1", true), nil
+		})
+
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	started := make(chan struct{})
+	var once sync.Once
+	_, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		once.Do(func() {
+			close(started)
+		})
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+	<-started
+	cancel()
+
+	// the handler never returns, so RunContext stops waiting for it after the timeout
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext doesn't return while a handler is stuck")
+	}
+}
+
+func TestSubscription_ExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		MaxAttempts:     6,
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		delay, ok := backoff.NextDelay(i + 1)
+		if !ok || delay != w {
+			t.Errorf("attempt %d: got %v %t, want %v true", i+1, delay, ok, w)
+		}
+	}
+	if _, ok := backoff.NextDelay(len(want) + 1); ok {
+		t.Errorf("attempt %d: expected to stop reconnecting", len(want)+1)
+	}
+
+	backoff.Jitter = 0.5
+	backoff.MaxAttempts = 0
+	for i := 0; i < 100; i++ {
+		delay, _ := backoff.NextDelay(2)
+		if delay < 100*time.Millisecond || delay > 300*time.Millisecond {
+			t.Fatalf("got delay %v, want between 100ms and 300ms", delay)
+		}
+		delay, _ = backoff.NextDelay(10)
+		if delay < 500*time.Millisecond || delay > time.Second {
+			t.Fatalf("got delay %v, want between 500ms and 1s", delay)
+		}
+	}
+}
+
+func TestSubscription_RunContext_backoff(t *testing.T) {
+	errDial := errors.New("connection refused")
+	dials := 0
+	var attempts []int
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithBackoff(ConstantBackoff{Interval: time.Millisecond, MaxAttempts: 3}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			if delay != time.Millisecond || err != errDial {
+				t.Errorf("got delay %v, error %v", delay, err)
+			}
+			attempts = append(attempts, attempt)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			return nil, errDial
+		})
+
+	err := sc.RunContext(context.Background())
+	if !errors.Is(err, errDial) {
+		t.Fatalf("got error: %v, want: %v", err, errDial)
+	}
+	if dials != 4 || fmt.Sprint(attempts) != "[1 2 3]" {
+		t.Errorf("got %d dials and attempts %v, want 4 dials and attempts [1 2 3]", dials, attempts)
+	}
+}
+
+func TestSubscription_RunContext_nonRetriable(t *testing.T) {
+	dials := 0
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithRetryClassifier(func(err error) bool {
+			var dialErr *WebsocketDialError
+			return !errors.As(err, &dialErr) || dialErr.StatusCode != http.StatusUnauthorized
+		}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			t.Errorf("unexpected reconnection attempt %d: %v", attempt, err)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			return nil, &WebsocketDialError{StatusCode: http.StatusUnauthorized, Err: errors.New("unauthorized")}
+		})
+
+	err := sc.RunContext(context.Background())
+	var dialErr *WebsocketDialError
+	if !errors.As(err, &dialErr) || dialErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got error: %v, want a dial error with status code 401", err)
+	}
+	if dials != 1 {
+		t.Errorf("got %d dials, want 1", dials)
+	}
+}
+
+// pongConn is a websocket connection that responds to the pings of the client while answering is set
+type pongConn struct {
+	*fakeConn
+	answering int32
+}
+
+func newPongConn() *pongConn {
+	conn := &pongConn{
+		fakeConn: &fakeConn{
+			messages: make(chan OperationMessage, 100),
+			closed:   make(chan struct{}),
+		},
+		answering: 1,
+	}
+	conn.messages <- OperationMessage{Type: GQLConnectionAck}
+	return conn
+}
+
+func (pc *pongConn) WriteJSON(v interface{}) error {
+	if message, ok := v.(OperationMessage); ok && message.Type == GQLPing && atomic.LoadInt32(&pc.answering) == 1 {
+		pc.messages <- OperationMessage{Type: GQLPong}
+	}
+	return pc.fakeConn.WriteJSON(v)
+}
+
+func TestSubscription_keepAlive_idle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dials := 0
+	var causes []error
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithExitWhenNoSubscription(false).
+		WithIdleTimeout(50 * time.Millisecond).
+		WithBackoff(ConstantBackoff{}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			causes = append(causes, err)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			if dials == 3 {
+				cancel()
+			}
+			// the server acknowledges the connection, then the connection is half-open
+			return newFakeConn(OperationMessage{Type: GQLConnectionAck}), nil
+		})
+
+	if got := sc.GetReadTimeout(); got != time.Minute {
+		t.Errorf("got read timeout %v, want the write timeout", got)
+	}
+	if got := sc.WithReadTimeout(-1).GetReadTimeout(); got != 0 {
+		t.Errorf("got read timeout %v, want 0", got)
+	}
+
+	if err := sc.RunContext(ctx); err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+	if len(causes) < 2 {
+		t.Fatalf("got %d reconnections, want at least 2", len(causes))
+	}
+	for _, cause := range causes {
+		if !errors.Is(cause, ErrKeepAliveTimeout) {
+			t.Errorf("got reconnection cause: %v, want: %v", cause, ErrKeepAliveTimeout)
+		}
+	}
+}
+
+// dataConn is a websocket connection that keeps sending data messages without keepalive messages
+type dataConn struct {
+	*fakeConn
+	acknowledged bool
+}
+
+func (dc *dataConn) ReadJSON(v interface{}) error {
+	if !dc.acknowledged {
+		dc.acknowledged = true
+		*v.(*OperationMessage) = OperationMessage{Type: GQLConnectionAck}
+		return nil
+	}
+	select {
+	case <-time.After(10 * time.Millisecond):
+		*v.(*OperationMessage) = OperationMessage{ID: "1", Type: GQLData, Payload: []byte(`{"data":{}}`)}
+		return nil
+	case <-dc.closed:
+		return context.Canceled
+	}
+}
+
+func TestSubscription_keepAlive_data(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	causes := make(chan error, 10)
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithExitWhenNoSubscription(false).
+		WithIdleTimeout(50 * time.Millisecond).
+		WithBackoff(ConstantBackoff{}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			causes <- err
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return &dataConn{fakeConn: newFakeConn()}, nil
+		})
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+
+	// data messages don't prove that the keepalive of the server works
+	select {
+	case cause := <-causes:
+		if !errors.Is(cause, ErrKeepAliveTimeout) {
+			t.Errorf("got reconnection cause: %v, want: %v", cause, ErrKeepAliveTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client doesn't reconnect when only data messages are received")
+	}
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+}
+
+// burstConn is a graphql-ws connection that sends a burst of data messages to each subscription,
+// and pings the client every 10 milliseconds
+type burstConn struct {
+	*fakeConn
+}
+
+func (bc *burstConn) ReadJSON(v interface{}) error {
+	select {
+	case message := <-bc.messages:
+		*v.(*OperationMessage) = message
+		return nil
+	case <-time.After(10 * time.Millisecond):
+		*v.(*OperationMessage) = OperationMessage{Type: GQLPing}
+		return nil
+	case <-bc.closed:
+		return context.Canceled
+	}
+}
+
+func (bc *burstConn) WriteJSON(v interface{}) error {
+	if message, ok := v.(OperationMessage); ok {
+		switch message.Type {
+		case GQLConnectionInit:
+			bc.messages <- OperationMessage{Type: GQLConnectionAck}
+		case GQLSubscribe:
+			for i := 0; i < 5; i++ {
+				bc.messages <- OperationMessage{ID: message.ID, Type: GQLNext, Payload: []byte(`{"data":{"user":{"id":"1"}}}`)}
+			}
+		}
+	}
+	return bc.fakeConn.WriteJSON(v)
+}
+
+func TestSubscription_keepAlive_blockedReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	causes := make(chan error, 10)
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithIdleTimeout(50*time.Millisecond).
+		WithDeliveryQueue(1, DeliveryBlock).
+		WithBackoff(ConstantBackoff{}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			causes <- err
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return &burstConn{&fakeConn{
+				messages: make(chan OperationMessage, 100),
+				closed:   make(chan struct{}),
+			}}, nil
+		})
+
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	received := make(chan struct{}, 5)
+	_, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		// the slow handler blocks the reader for longer than the idle timeout
+		time.Sleep(100 * time.Millisecond)
+		received <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+
+	// the time the reader is blocked by the full queue isn't counted against the connection
+	for i := 0; i < 5; i++ {
+		select {
+		case <-received:
+		case cause := <-causes:
+			t.Fatalf("unexpected reconnection: %v", cause)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the messages")
+		}
+	}
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestSubscription_keepAlive_ping(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn := newPongConn()
+	var dials int32
+	causes := make(chan error, 10)
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithExitWhenNoSubscription(false).
+		WithPing(20*time.Millisecond, 50*time.Millisecond).
+		WithBackoff(ConstantBackoff{}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			causes <- err
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return conn, nil
+			}
+			return newPongConn(), nil
+		})
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+
+	// the connection is alive while the server responds to the pings
+	time.Sleep(300 * time.Millisecond)
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Fatalf("got %d dials, want 1", got)
+	}
+	pings := 0
+	for _, ty := range conn.writtenTypes() {
+		if ty == GQLPing {
+			pings++
+		}
+	}
+	if pings < 5 {
+		t.Errorf("got %d pings, want at least 5", pings)
+	}
+
+	// the client reconnects when the server stops responding
+	atomic.StoreInt32(&conn.answering, 0)
+	select {
+	case cause := <-causes:
+		if !errors.Is(cause, ErrKeepAliveTimeout) {
+			t.Errorf("got reconnection cause: %v, want: %v", cause, ErrKeepAliveTimeout)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the client doesn't reconnect when the server stops responding to pings")
+	}
+
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestSubscription_connectionAckTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dials := 0
+	var causes []error
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithExitWhenNoSubscription(false).
+		WithConnectionAckTimeout(30 * time.Millisecond).
+		WithBackoff(ConstantBackoff{}).
+		OnReconnecting(func(attempt int, delay time.Duration, err error) {
+			causes = append(causes, err)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			if dials == 3 {
+				cancel()
+			}
+			// the server never acknowledges the connection
+			return newFakeConn(), nil
+		})
+
+	if err := sc.RunContext(ctx); err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+	if len(causes) < 2 {
+		t.Fatalf("got %d reconnections, want at least 2", len(causes))
+	}
+	for _, cause := range causes {
+		if !errors.Is(cause, ErrConnectionAckTimeout) {
+			t.Errorf("got reconnection cause: %v, want: %v", cause, ErrConnectionAckTimeout)
+		}
+	}
+
+	// the client fails if the timeout isn't retriable
+	dials = 0
+	sc = NewSubscriptionClient("ws://localhost/graphql").
+		WithExitWhenNoSubscription(false).
+		WithConnectionAckTimeout(30 * time.Millisecond).
+		WithRetryClassifier(func(err error) bool {
+			return !errors.Is(err, ErrConnectionAckTimeout)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			dials++
+			return newFakeConn(), nil
+		})
+
+	if err := sc.RunContext(context.Background()); !errors.Is(err, ErrConnectionAckTimeout) {
+		t.Fatalf("got error: %v, want: %v", err, ErrConnectionAckTimeout)
+	}
+	if dials != 1 {
+		t.Errorf("got %d dials, want 1", dials)
+	}
+}
+
+func TestSubscription_OnConnectionAck(t *testing.T) {
+	for _, protocol := range []SubscriptionProtocolType{SubscriptionsTransportWS, GraphQLWS} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var payload []byte
+		sc := NewSubscriptionClient("ws://localhost/graphql").
+			WithProtocol(protocol).
+			WithExitWhenNoSubscription(false).
+			OnConnectionAck(func(p []byte) {
+				payload = p
+				cancel()
+			}).
+			WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+				return newFakeConn(OperationMessage{Type: GQLConnectionAck, Payload: []byte(`{"version":"v2"}`)}), nil
+			})
+
+		if err := sc.RunContext(ctx); err != context.Canceled {
+			t.Fatalf("%s: got error: %v, want: %v", protocol, err, context.Canceled)
+		}
+		if string(payload) != `{"version":"v2"}` {
+			t.Errorf("%s: got payload %s, want %s", protocol, payload, `{"version":"v2"}`)
+		}
+	}
+}
+
+// closeConn is a websocket connection that the server closes with err
+type closeConn struct {
+	*fakeConn
+	err error
+}
+
+func (cc *closeConn) ReadJSON(v interface{}) error {
+	return cc.err
+}
+
+func (cc *closeConn) GetCloseStatus(err error) int32 {
+	return int32(websocket.CloseStatus(err))
+}
+
+func TestSubscription_WebsocketCloseError(t *testing.T) {
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			return &closeConn{
+				fakeConn: newFakeConn(),
+				err:      fmt.Errorf("failed to read JSON message: %w", websocket.CloseError{Code: StatusUnauthorized, Reason: "Unauthorized"}),
+			}, nil
+		})
+
+	err := sc.RunContext(context.Background())
+	var closeErr *WebsocketCloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("got error: %v, want a close error", err)
+	}
+	if closeErr.Code != StatusUnauthorized || closeErr.Reason != "Unauthorized" {
+		t.Errorf("got code %d and reason %q, want %d and %q", closeErr.Code, closeErr.Reason, StatusUnauthorized, "Unauthorized")
+	}
+}
+
+// serverConn is a graphql-ws connection that acknowledges the connection if ack is set,
+// and responds to each subscribe message with the data of the name of the connection
+type serverConn struct {
+	*fakeConn
+	name string
+	ack  bool
+	// lost is closed to lose the connection
+	lost chan struct{}
+}
+
+func newServerConn(name string, ack bool) *serverConn {
+	return &serverConn{
+		fakeConn: &fakeConn{
+			messages: make(chan OperationMessage, 100),
+			closed:   make(chan struct{}),
+		},
+		name: name,
+		ack:  ack,
+		lost: make(chan struct{}),
+	}
+}
+
+func (sc *serverConn) ReadJSON(v interface{}) error {
+	select {
+	case <-sc.lost:
+		return io.EOF
+	default:
+	}
+	select {
+	case message := <-sc.messages:
+		*v.(*OperationMessage) = message
+		return nil
+	case <-sc.closed:
+		return context.Canceled
+	case <-sc.lost:
+		return io.EOF
+	}
+}
+
+func (sc *serverConn) WriteJSON(v interface{}) error {
+	if message, ok := v.(OperationMessage); ok {
+		switch message.Type {
+		case GQLConnectionInit:
+			if sc.ack {
+				sc.messages <- OperationMessage{Type: GQLConnectionAck}
+			}
+		case GQLSubscribe:
+			sc.messages <- OperationMessage{
+				ID:      message.ID,
+				Type:    GQLNext,
+				Payload: []byte(fmt.Sprintf(`{"data":{"user":{"id":%q}}}`, sc.name)),
+			}
+		}
+	}
+	return sc.fakeConn.WriteJSON(v)
+}
+
+func (sc *serverConn) isClosed() bool {
+	select {
+	case <-sc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSubscription_RefreshConnection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	var conns []*serverConn
+	tokens := 0
+	disconnected := int32(0)
+	sc := NewSubscriptionClient("ws://localhost/graphql").
+		WithProtocol(GraphQLWS).
+		WithConnectionAckTimeout(100 * time.Millisecond).
+		WithConnectionParamsFn(func() map[string]interface{} {
+			lock.Lock()
+			defer lock.Unlock()
+			tokens++
+			return map[string]interface{}{"token": tokens}
+		}).
+		OnDisconnected(func() {
+			atomic.AddInt32(&disconnected, 1)
+		}).
+		WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			// the third connection isn't acknowledged, so the refresh fails
+			conn := newServerConn(fmt.Sprint(len(conns)+1), len(conns) != 2)
+			conns = append(conns, conn)
+			return conn, nil
+		})
+
+	received := make(chan string, 10)
+	var sub struct {
+		User struct {
+			ID string
+		}
+	}
+	handle, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+		if err != nil {
+			t.Errorf("got error: %v, want: nil", err)
+			return nil
+		}
+		var result struct {
+			User struct {
+				ID string
+			}
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Error(err)
+		}
+		received <- result.User.ID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error)
+	go func() {
+		result <- sc.RunContext(ctx)
+	}()
+
+	expectData := func(want string) {
+		t.Helper()
+		select {
+		case got := <-received:
+			if got != want {
+				t.Fatalf("got data from connection %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for the data of connection %s", want)
+		}
+	}
+	getConns := func() []*serverConn {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]*serverConn(nil), conns...)
+	}
+	waitFor := func(condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the refresh")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	expectData("1")
+
+	// the subscription moves to the second connection before the first one is closed
+	sc.RefreshConnection()
+	expectData("2")
+	waitFor(func() bool {
+		return getConns()[0].isClosed()
+	})
+	want := []OperationMessageType{GQLConnectionInit, GQLSubscribe, GQLComplete}
+	if got := getConns()[0].writtenTypes(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got messages %v on the first connection, want %v", got, want)
+	}
+
+	// the subscription keeps running on the second connection if the third connection fails
+	sc.RefreshConnection()
+	waitFor(func() bool {
+		conns := getConns()
+		return len(conns) == 3 && conns[2].isClosed()
+	})
+	if getConns()[1].isClosed() {
+		t.Error("the second connection is closed after the refresh failed")
+	}
+
+	// in-band re-authentication keeps the connection
+	reauth := make(chan map[string]interface{}, 1)
+	sc.WithReauth(func(ctx *SubscriptionContext, params map[string]interface{}) error {
+		reauth <- params
+		return nil
+	})
+	sc.RefreshConnection()
+	select {
+	case params := <-reauth:
+		if params["token"] != 4 {
+			t.Errorf("got connection params %v, want token 4", params)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the re-authentication")
+	}
+
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Fatalf("got error: %v, want: %v", err, context.Canceled)
+	}
+	if got := len(getConns()); got != 3 {
+		t.Errorf("got %d connections, want 3", got)
+	}
+	if handle.Err() != ErrSubscriptionClientClosed {
+		t.Errorf("got error: %v, want: %v", handle.Err(), ErrSubscriptionClientClosed)
+	}
+	// OnDisconnected is triggered when the client is closed only
+	if got := atomic.LoadInt32(&disconnected); got != 1 {
+		t.Errorf("got %d disconnections, want 1", got)
+	}
+}
+
+func TestSubscription_RefreshConnection_pending(t *testing.T) {
+	for _, event := range []string{"cancel", "close", "lost"} {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var lock sync.Mutex
+		var conns []*serverConn
+		sc := NewSubscriptionClient("ws://localhost/graphql").
+			WithProtocol(GraphQLWS).
+			WithConnectionAckTimeout(time.Minute).
+			WithBackoff(ConstantBackoff{}).
+			WithWebSocket(func(sc *SubscriptionClient) (WebsocketConn, error) {
+				lock.Lock()
+				defer lock.Unlock()
+				// the second connection isn't acknowledged, so the refresh waits for the ack timeout
+				conn := newServerConn(fmt.Sprint(len(conns)+1), len(conns) != 1)
+				conns = append(conns, conn)
+				return conn, nil
+			})
+		getConns := func() []*serverConn {
+			lock.Lock()
+			defer lock.Unlock()
+			return append([]*serverConn(nil), conns...)
+		}
+
+		received := make(chan string, 10)
+		var sub struct {
+			User struct {
+				ID string
+			}
+		}
+		_, err := sc.Subscribe(&sub, nil, func(data []byte, err error) error {
+			var result struct {
+				User struct {
+					ID string
+				}
+			}
+			if err := json.Unmarshal(data, &result); err != nil {
+				t.Error(err)
+			}
+			received <- result.User.ID
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := make(chan error)
+		go func() {
+			result <- sc.RunContext(ctx)
+		}()
+		expectData := func(want string) {
+			t.Helper()
+			select {
+			case got := <-received:
+				if got != want {
+					t.Fatalf("%s: got data from connection %s, want %s", event, got, want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: timed out waiting for the data of connection %s", event, want)
+			}
+		}
+		expectData("1")
+
+		sc.RefreshConnection()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(getConns()) != 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: timed out waiting for the refresh", event)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// the pending refresh doesn't delay the handling of the cancellation, Close or errors until the ack timeout
+		var want error
+		switch event {
+		case "cancel":
+			cancel()
+			want = context.Canceled
+		case "close":
+			if err := sc.Close(); err != nil {
+				t.Errorf("got error: %v, want: nil", err)
+			}
+		case "lost":
+			close(getConns()[0].lost)
+			// the refresh is abandoned and the client reconnects
+			expectData("3")
+			if !getConns()[1].isClosed() {
+				t.Error("the connection of the abandoned refresh isn't closed")
+			}
+			cancel()
+			want = context.Canceled
+		}
+		select {
+		case err := <-result:
+			if err != want {
+				t.Errorf("%s: got error: %v, want: %v", event, err, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: RunContext didn't return while refreshing", event)
+		}
+		for i, conn := range getConns() {
+			if !conn.isClosed() {
+				t.Errorf("%s: connection %d isn't closed", event, i+1)
+			}
+		}
+		cancel()
+	}
+}
+
 func TestSubscription_closeThenRun(t *testing.T) {
 	_, subscriptionClient := hasura_setupClients(GraphQLWS)
 
@@ -361,7 +1731,7 @@ func TestSubscription_closeThenRun(t *testing.T) {
 	bulkSubscribe := func() {
 
 		for _, f := range fixtures {
-			id, err := subscriptionClient.Subscribe(f.Query, f.Variables, func(data []byte, e error) error {
+			handle, err := subscriptionClient.Subscribe(f.Query, f.Variables, func(data []byte, e error) error {
 				if e != nil {
 					t.Fatalf("got error: %v, want: nil", e)
 					return nil
@@ -372,7 +1742,7 @@ func TestSubscription_closeThenRun(t *testing.T) {
 			if err != nil {
 				t.Fatalf("got error: %v, want: nil", err)
 			}
-			log.Printf("subscribed: %s", id)
+			log.Printf("subscribed: %s", handle.ID())
 		}
 	}
 
@@ -179,6 +179,7 @@ func (stw *subscriptionsTransportWS) OnMessage(ctx *SubscriptionContext, subscri
 		}
 		if sub != nil {
 			ctx.SetSubscription(sub.GetKey(), nil)
+			sub.handle.finish(ErrSubscriptionCompleted)
 		}
 	case GQLConnectionKeepAlive:
 		ctx.Log(message, "server", GQLConnectionKeepAlive)
@@ -190,6 +191,9 @@ func (stw *subscriptionsTransportWS) OnMessage(ctx *SubscriptionContext, subscri
 		// The client is now ready to request subscription operations.
 		ctx.Log(message, "server", GQLConnectionAck)
 		ctx.SetAcknowledge(true)
+		if ctx.OnConnectionAck != nil {
+			ctx.OnConnectionAck(message.Payload)
+		}
 		subscriptions := ctx.GetSubscriptions()
 		for id, sub := range subscriptions {
 			if err := stw.Subscribe(ctx, sub); err != nil {
@@ -396,8 +396,8 @@ func TestTransportWS_exitWhenNoSubscription(t *testing.T) {
 		}
 
 		time.Sleep(2 * time.Second)
-		subscriptionClient.Unsubscribe(subId1)
-		subscriptionClient.Unsubscribe(subId2)
+		subId1.Unsubscribe()
+		subId2.Unsubscribe()
 	}()
 
 	defer subscriptionClient.Close()
@@ -407,6 +407,169 @@ func TestTransportWS_exitWhenNoSubscription(t *testing.T) {
 	}
 }
 
+func TestTransportWS_subscribeChan(t *testing.T) {
+	server := subscription_setupServer(8086)
+	client, subscriptionClient := subscription_setupClients(8086)
+	msg := randomID()
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer server.Shutdown(ctx)
+	defer cancel()
+
+	var sub struct {
+		HelloSaid struct {
+			ID      String
+			Message String `graphql:"msg" json:"msg"`
+		} `graphql:"helloSaid" json:"helloSaid"`
+	}
+
+	subCtx, subCancel := context.WithCancel(ctx)
+	events, err := subscriptionClient.SubscribeChan(subCtx, sub, nil)
+	if err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+
+	go func() {
+		if err := subscriptionClient.Run(); err != nil {
+			log.Println(err)
+		}
+	}()
+	defer subscriptionClient.Close()
+
+	// wait until the subscription client connects to the server
+	time.Sleep(2 * time.Second)
+
+	var q struct {
+		SayHello struct {
+			ID  String
+			Msg String
+		} `graphql:"sayHello(msg: $msg)"`
+	}
+	variables := map[string]interface{}{
+		"msg": String(msg),
+	}
+	if err = client.Mutate(context.Background(), &q, variables, OperationName("SayHello")); err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+
+	event := <-events
+	if event.Type != SubscriptionEventData {
+		t.Fatalf("got event %+v, want data", event)
+	}
+	if err := json.Unmarshal(event.Data, &sub); err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+	if sub.HelloSaid.Message != String(msg) {
+		t.Fatalf("subscription message does not match. got: %s, want: %s", sub.HelloSaid.Message, msg)
+	}
+
+	subCancel()
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the channel isn't closed after the context is cancelled")
+		}
+	}
+}
+
+func TestTransportWS_resubscribe(t *testing.T) {
+	server := subscription_setupServer(8087)
+	client, subscriptionClient := subscription_setupClients(8087)
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer server.Shutdown(ctx)
+	defer cancel()
+
+	var sub struct {
+		HelloSaid struct {
+			ID      String
+			Message String `graphql:"msg" json:"msg"`
+		} `graphql:"helloSaid" json:"helloSaid"`
+	}
+	messages := make(chan string, 10)
+	handle, err := subscriptionClient.Subscribe(sub, nil, func(data []byte, e error) error {
+		if e != nil {
+			return e
+		}
+		messages <- string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+
+	go func() {
+		if err := subscriptionClient.Run(); err != nil {
+			log.Println(err)
+		}
+	}()
+	defer subscriptionClient.Close()
+
+	sayHello := func() {
+		var q struct {
+			SayHello struct {
+				ID  String
+				Msg String
+			} `graphql:"sayHello(msg: $msg)"`
+		}
+		variables := map[string]interface{}{
+			"msg": String(randomID()),
+		}
+		if err := client.Mutate(context.Background(), &q, variables, OperationName("SayHello")); err != nil {
+			t.Fatalf("got error: %v, want: nil", err)
+		}
+		select {
+		case <-messages:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the subscription message")
+		}
+	}
+
+	// wait until the subscription client connects to the server
+	time.Sleep(2 * time.Second)
+	sayHello()
+	if handle.Status() != SubscriptionRunning {
+		t.Fatalf("got status %d, want running", handle.Status())
+	}
+	serverID := subscriptionClient.getContext().GetSubscription(handle.ID()).GetID()
+
+	if err := handle.Resubscribe(nil); err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+	time.Sleep(time.Second)
+	sayHello()
+
+	state := subscriptionClient.getContext().GetSubscription(handle.ID())
+	if state == nil || state.GetID() == serverID {
+		t.Fatalf("got subscription %+v, want a new operation id with the same key", state)
+	}
+	if stats := handle.Stats(); stats.Messages != 2 {
+		t.Errorf("got %d messages, want 2", stats.Messages)
+	}
+
+	if err := handle.Unsubscribe(); err != nil {
+		t.Fatalf("got error: %v, want: nil", err)
+	}
+	<-handle.Done()
+	if handle.Err() != ErrSubscriptionUnsubscribed {
+		t.Errorf("got error: %v, want: %v", handle.Err(), ErrSubscriptionUnsubscribed)
+	}
+}
+
 func TestTransportWS_ResetClient(t *testing.T) {
 
 	stop := make(chan bool)
@@ -437,6 +600,8 @@ func TestTransportWS_ResetClient(t *testing.T) {
 		} `graphql:"user(order_by: { id: desc }, limit: 5)"`
 	}
 
+	// the operation ids that are sent to the server before the client is reset
+	var serverID1, serverID2 string
 	subId1, err := subscriptionClient.Subscribe(sub, nil, func(data []byte, e error) error {
 		if e != nil {
 			t.Fatalf("got error: %v, want: nil", e)
@@ -541,28 +706,24 @@ func TestTransportWS_ResetClient(t *testing.T) {
 		time.Sleep(2 * time.Second)
 
 		// test susbcription ids
-		sub1 := subscriptionClient.getContext().GetSubscription(subId1)
+		sub1 := subscriptionClient.getContext().GetSubscription(subId1.ID())
 		if sub1 == nil {
-			(*t).Fatalf("subscription 1 not found: %s", subId1)
+			(*t).Fatalf("subscription 1 not found: %s", subId1.ID())
 		} else {
-			if sub1.key != subId1 {
-				(*t).Fatalf("subscription key 1 not equal, got %s, want %s", subId1, sub1.key)
-			}
-			if sub1.id != subId1 {
-				(*t).Fatalf("subscription id 1 not equal, got %s, want %s", subId1, sub1.id)
+			if sub1.key != subId1.ID() {
+				(*t).Fatalf("subscription key 1 not equal, got %s, want %s", subId1.ID(), sub1.key)
 			}
+			serverID1 = sub1.id
 		}
-		sub2 := subscriptionClient.getContext().GetSubscription(subId2)
+		sub2 := subscriptionClient.getContext().GetSubscription(subId2.ID())
 		if sub2 == nil {
-			(*t).Fatalf("subscription 2 not found: %s", subId2)
+			(*t).Fatalf("subscription 2 not found: %s", subId2.ID())
 		} else {
-			if sub2.key != subId2 {
-				(*t).Fatalf("subscription id 2 not equal, got %s, want %s", subId2, sub2.key)
+			if sub2.key != subId2.ID() {
+				(*t).Fatalf("subscription id 2 not equal, got %s, want %s", subId2.ID(), sub2.key)
 			}
 
-			if sub2.id != subId2 {
-				(*t).Fatalf("subscription id 2 not equal, got %s, want %s", subId2, sub2.id)
-			}
+			serverID2 = sub2.id
 		}
 
 		// reset the subscription
@@ -578,32 +739,32 @@ func TestTransportWS_ResetClient(t *testing.T) {
 		time.Sleep(8 * time.Second)
 
 		// test subscription ids
-		sub1 := subscriptionClient.getContext().GetSubscription(subId1)
+		sub1 := subscriptionClient.getContext().GetSubscription(subId1.ID())
 		if sub1 == nil {
-			(*t).Fatalf("subscription 1 not found: %s", subId1)
+			(*t).Fatalf("subscription 1 not found: %s", subId1.ID())
 		} else {
-			if sub1.key != subId1 {
-				(*t).Fatalf("subscription key 1 not equal, got %s, want %s", subId1, sub1.key)
+			if sub1.key != subId1.ID() {
+				(*t).Fatalf("subscription key 1 not equal, got %s, want %s", subId1.ID(), sub1.key)
 			}
-			if sub1.id == subId1 {
-				(*t).Fatalf("subscription id 1 should equal, got %s, want %s", subId1, sub1.id)
+			if sub1.id == serverID1 {
+				(*t).Fatalf("subscription id 1 should be regenerated, got %s", sub1.id)
 			}
 		}
-		sub2 := subscriptionClient.getContext().GetSubscription(subId2)
+		sub2 := subscriptionClient.getContext().GetSubscription(subId2.ID())
 		if sub2 == nil {
-			(*t).Fatalf("subscription 2 not found: %s", subId2)
+			(*t).Fatalf("subscription 2 not found: %s", subId2.ID())
 		} else {
-			if sub2.key != subId2 {
-				(*t).Fatalf("subscription id 2 not equal, got %s, want %s", subId2, sub2.key)
+			if sub2.key != subId2.ID() {
+				(*t).Fatalf("subscription id 2 not equal, got %s, want %s", subId2.ID(), sub2.key)
 			}
 
-			if sub2.id == subId2 {
-				(*t).Fatalf("subscription id 2 should equal, got %s, want %s", subId2, sub2.id)
+			if sub2.id == serverID2 {
+				(*t).Fatalf("subscription id 2 should be regenerated, got %s", sub2.id)
 			}
 		}
 
-		subscriptionClient.Unsubscribe(subId1)
-		subscriptionClient.Unsubscribe(subId2)
+		subId1.Unsubscribe()
+		subId2.Unsubscribe()
 	}()
 
 	defer subscriptionClient.Close()
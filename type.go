@@ -14,6 +14,17 @@ type GraphQLType interface {
 	GetGraphQLType() string
 }
 
+// GraphQLFragment interface is used to declare a struct type as a named GraphQL fragment.
+// Wherever the type is used in a query, the fragment spread ...<name> is written
+// instead of the expanded fields, and the definition
+// `fragment <name> on <typeCondition> {...}` is appended to the document once.
+// Embed the type into a struct to spread the fragment into the parent selection set.
+//
+// Similar to GraphQLType, the GetGraphQLFragment function is applied to the zero value of the type.
+type GraphQLFragment interface {
+	GetGraphQLFragment() (name string, typeCondition string)
+}
+
 // GraphQLRequestPayload represents the graphql JSON-encoded request body
 // https://graphql.org/learn/serving-over-http/#post-request
 type GraphQLRequestPayload struct {
@@ -0,0 +1,507 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/hasura/go-graphql-client/ident"
+	"github.com/hasura/go-graphql-client/pkg/jsonutil"
+	"github.com/hasura/go-graphql-client/pkg/parser"
+)
+
+// variableFieldsCache caches []variableField by reflect.Type of variables structs
+var variableFieldsCache sync.Map
+
+// variableField is a field of a variables struct, with the parts of the definition given by its tags
+type variableField struct {
+	index []int
+	name  string
+	// graphqlType is the type of the type tag, or empty if the type is derived from the Go type
+	graphqlType  string
+	nullable     bool
+	defaultValue string
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// variableEncodingTypes caches needsVariableEncoding by variableEncodingKey
+var variableEncodingTypes sync.Map
+
+type variableEncodingKey struct {
+	t        reflect.Type
+	registry uint64
+}
+
+// scalarValue is a value of a registered scalar that is encoded by the config of the scalar
+type scalarValue struct {
+	value interface{}
+}
+
+func (v scalarValue) MarshalJSON() ([]byte, error) {
+	return jsonutil.MarshalScalar(v.value)
+}
+
+// typedVariable is the value of a variables struct field with the definition of the variable,
+// e.g. "Int=10" or "[ID!]!". It's encoded as the value in the JSON body
+type typedVariable struct {
+	value      interface{}
+	definition string
+}
+
+func (v typedVariable) MarshalJSON() ([]byte, error) {
+	value, _ := variableJSONValue(reflect.ValueOf(v.value))
+	return json.Marshal(value)
+}
+
+// variablesMap converts the variables argument of the client to the map of variables.
+// It accepts nil, maps with string keys and structs, or pointers to them.
+//
+// The fields of structs are variables named by the graphql tag, or the field name in lowerCamelCase.
+// Their GraphQL types are derived from the Go types, the same as values of maps,
+// unless the type tag is set. The nullable option of the graphql tag removes the non-null "!" of the type,
+// and the default tag is the default value, e.g.
+//
+//	struct {
+//		ID    string `graphql:"id" type:"ID!"`
+//		First int    `graphql:"first,nullable" default:"10"`
+//	}
+//
+// defines "$id:ID!$first:Int=10". Untagged embedded structs are flattened and fields tagged "-" are skipped
+func variablesMap(variables interface{}) (map[string]interface{}, error) {
+	switch vars := variables.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return vars, nil
+	}
+
+	v := reflect.ValueOf(variables)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("the keys of variables must be strings, got %v", v.Type())
+		}
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = iter.Value().Interface()
+		}
+		return result, nil
+	case reflect.Struct:
+		fields, err := cachedVariableFields(v.Type())
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				// the field of a nil embedded pointer isn't set
+				continue
+			}
+			definition, err := f.definition(fv)
+			if err != nil {
+				return nil, err
+			}
+			result[f.name] = typedVariable{value: fv.Interface(), definition: definition}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("variables must be a map or a struct, got %T", variables)
+}
+
+// definition returns the type and the default value of the variable definition of field value v
+func (f variableField) definition(v reflect.Value) (string, error) {
+	graphqlType := f.graphqlType
+	if graphqlType == "" {
+		t := f.fieldType(v)
+		if t == nil {
+			return "", fmt.Errorf("can't derive the GraphQL type of variable %q from nil interface, set the type tag", f.name)
+		}
+		var value interface{}
+		if v.Kind() != reflect.Interface || !v.IsNil() {
+			value = v.Interface()
+		}
+		var buf bytes.Buffer
+		writeArgumentType(&buf, t, value, true)
+		graphqlType = buf.String()
+		if strings.Trim(graphqlType, "[]!") == "" {
+			// unnamed types, e.g. anonymous structs
+			return "", fmt.Errorf("can't derive the GraphQL type of variable %q from %v, set the type tag", f.name, t)
+		}
+	}
+	if f.nullable {
+		graphqlType = strings.TrimSuffix(graphqlType, "!")
+	}
+	if f.defaultValue != "" {
+		return graphqlType + "=" + f.defaultValue, nil
+	}
+	return graphqlType, nil
+}
+
+// fieldType returns the Go type that the GraphQL type is derived from,
+// which is the dynamic type of interface fields, or nil if the interface is nil
+func (f variableField) fieldType(v reflect.Value) reflect.Type {
+	if v.Kind() != reflect.Interface {
+		return v.Type()
+	}
+	if v.IsNil() {
+		return nil
+	}
+	return v.Elem().Type()
+}
+
+// fieldByIndex returns the nested field of struct v, or false if it's in a nil embedded pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// cachedVariableFields returns the variable fields of struct type t, which are parsed once per type
+func cachedVariableFields(t reflect.Type) ([]variableField, error) {
+	if fields, ok := variableFieldsCache.Load(t); ok {
+		return fields.([]variableField), nil
+	}
+	fields, err := newVariableFields(t, nil, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	variableFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func newVariableFields(t reflect.Type, index []int, names map[string]bool) ([]variableField, error) {
+	var fields []variableField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		value, tagged := f.Tag.Lookup("graphql")
+		if value == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !tagged && ft.Kind() == reflect.Struct && !ft.Implements(graphqlTypeInterface) {
+			embedded, err := newVariableFields(ft, fieldIndex, names)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+
+		parts := strings.Split(value, ",")
+		field := variableField{
+			index:        fieldIndex,
+			name:         strings.TrimSpace(parts[0]),
+			graphqlType:  f.Tag.Get("type"),
+			defaultValue: f.Tag.Get("default"),
+		}
+		for _, option := range parts[1:] {
+			switch strings.TrimSpace(option) {
+			case "nullable":
+				field.nullable = true
+			default:
+				return nil, fmt.Errorf("invalid option %q of variable field `%v`", option, f.Name)
+			}
+		}
+		if field.name == "" {
+			field.name = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+		}
+		if names[field.name] {
+			return nil, fmt.Errorf("duplicate variable %q of field `%v`", field.name, f.Name)
+		}
+		names[field.name] = true
+		if err := field.validate(); err != nil {
+			return nil, fmt.Errorf("invalid variable field `%v`: %w", f.Name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// validate parses the name, the type and the default value of the variable with the GraphQL grammar.
+// Derived types are written by writeArgumentType, the same as the types of map values
+func (f variableField) validate() error {
+	graphqlType := f.graphqlType
+	if graphqlType == "" {
+		// any type to check the name and the default value
+		graphqlType = "T"
+	}
+	definition := "$" + f.name + ":" + graphqlType
+	if f.defaultValue != "" {
+		definition += "=" + f.defaultValue
+	}
+	doc, err := parser.Parse("query(" + definition + "){__typename}")
+	if err != nil {
+		return err
+	}
+	if defs := doc.Operations[0].VariableDefinitions; len(defs) != 1 || len(defs[0].Directives) > 0 {
+		return fmt.Errorf("expected a single variable definition, got %q", definition)
+	}
+	return nil
+}
+
+// encodeVariables returns the variables to be encoded in the request body.
+// Optional values are replaced by their values or null, and omitted values are left out
+// of the variables and the objects of input values. Values of registered scalars are encoded by their config
+func encodeVariables(variables map[string]interface{}) map[string]interface{} {
+	var encoded map[string]interface{}
+	for name, value := range variables {
+		if v, ok := value.(typedVariable); ok {
+			value = v.value
+		}
+		if value == nil || !needsVariableEncoding(reflect.TypeOf(value)) {
+			continue
+		}
+		if encoded == nil {
+			// the map of the caller isn't modified
+			encoded = make(map[string]interface{}, len(variables))
+			for k, v := range variables {
+				encoded[k] = v
+			}
+		}
+		jsonValue, omitted := variableJSONValue(reflect.ValueOf(value))
+		if omitted {
+			delete(encoded, name)
+		} else {
+			encoded[name] = jsonValue
+		}
+	}
+	if encoded == nil {
+		return variables
+	}
+	return encoded
+}
+
+// needsVariableEncoding reports whether values of type t may contain Optional values or registered scalars
+// that are encoded differently from encoding/json
+func needsVariableEncoding(t reflect.Type) bool {
+	key := variableEncodingKey{t: t, registry: jsonutil.RegistryVersion()}
+	if needs, ok := variableEncodingTypes.Load(key); ok {
+		return needs.(bool)
+	}
+	needs := typeNeedsVariableEncoding(t, map[reflect.Type]bool{})
+	variableEncodingTypes.Store(key, needs)
+	return needs
+}
+
+func typeNeedsVariableEncoding(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t == optionalType {
+		return true
+	}
+	if config, ok := jsonutil.RegisteredScalar(t); ok {
+		return config.Marshal != nil
+	}
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	if t.Implements(jsonMarshaler) || t.Implements(textMarshaler) {
+		// the type encodes itself
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return typeNeedsVariableEncoding(t.Elem(), visited)
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && typeNeedsVariableEncoding(t.Elem(), visited)
+	case reflect.Interface:
+		// the dynamic value may be an Optional or a registered scalar
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if typeNeedsVariableEncoding(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// variableJSONValue converts v to a value that is encoded by encoding/json
+// with the values of Optional fields and the encoding of registered scalars. It reports true if v is an omitted Optional.
+//
+// Structs are converted to maps by the names of the json tags. Fields tagged "-" and
+// empty fields with the omitempty option are left out, and untagged embedded structs are flattened
+func variableJSONValue(v reflect.Value) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	if v.Type() == optionalType {
+		o := v.Interface().(Optional)
+		switch {
+		case o.IsOmitted():
+			return nil, true
+		case o.IsNull():
+			return nil, false
+		}
+		value, _ := variableJSONValue(reflect.ValueOf(o.Value()))
+		return value, false
+	}
+	if !needsVariableEncoding(v.Type()) {
+		return v.Interface(), false
+	}
+	if _, ok := jsonutil.RegisteredScalar(v.Type()); ok {
+		return scalarValue{value: v.Interface()}, false
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return variableJSONValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			// omitted items of lists are null
+			items[i], _ = variableJSONValue(v.Index(i))
+		}
+		return items, false
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		object := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if value, omitted := variableJSONValue(iter.Value()); !omitted {
+				object[iter.Key().String()] = value
+			}
+		}
+		return object, false
+	case reflect.Struct:
+		object := map[string]interface{}{}
+		writeVariableStruct(object, v)
+		return object, false
+	}
+	return v.Interface(), false
+}
+
+// writeVariableStruct sets the fields of struct v to object, by the rules of encoding/json.
+// Fields of embedded structs don't replace the fields of the outer struct
+func writeVariableStruct(object map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	var embedded []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if hasJSONOption(parts[1:], "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		value, omitted := variableJSONValue(fv)
+		if omitted {
+			continue
+		}
+		if hasJSONOption(parts[1:], "string") && isStringOptionKind(fv.Kind()) {
+			// the JSON encoding of the value is quoted
+			b, err := json.Marshal(value)
+			if err == nil {
+				value = string(b)
+			}
+		}
+		object[name] = value
+	}
+	for _, fv := range embedded {
+		fields := map[string]interface{}{}
+		writeVariableStruct(fields, fv)
+		for name, value := range fields {
+			if _, ok := object[name]; !ok {
+				object[name] = value
+			}
+		}
+	}
+}
+
+func isStringOptionKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func hasJSONOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue reports whether v is empty by the omitempty rules of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}